@@ -1,61 +1,112 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// the time zones of employee profiles are validated against the
+	// embedded database, as minimal images ship without one
+	_ "time/tzdata"
 
-	"github.com/joho/godotenv"
+	"github.com/moura1001/aws-employee-directory-application/server/config"
 	server "github.com/moura1001/aws-employee-directory-application/server/handler"
+	"github.com/moura1001/aws-employee-directory-application/server/logging"
+	"github.com/moura1001/aws-employee-directory-application/server/tracing"
 	"github.com/moura1001/aws-employee-directory-application/server/utils"
 )
 
-func init() {
-	err := godotenv.Load(".env")
+func main() {
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatalf("error loading .env file: %v", err)
-	}
-
-	PHOTOS_BUCKET := os.Getenv("PHOTOS_BUCKET")
-	CSRF_SECRET := os.Getenv("CSRF_SECRET")
-	DATABASE_HOST := os.Getenv("DATABASE_HOST")
-	DATABASE_USER := os.Getenv("DATABASE_USER")
-	DATABASE_PASSWORD := os.Getenv("DATABASE_PASSWORD")
-	DATABASE_DB_NAME := os.Getenv("DATABASE_DB_NAME")
-	DYNAMO_MODE := os.Getenv("DYNAMO_MODE")
-	AWS_DEFAULT_REGION := os.Getenv("AWS_DEFAULT_REGION")
-	SESSION_KEY := os.Getenv("SESSION_KEY")
-
-	utils.PHOTOS_BUCKET = PHOTOS_BUCKET
-	utils.CSRF_SECRET = CSRF_SECRET
-	utils.DATABASE_HOST = DATABASE_HOST
-	utils.DATABASE_USER = DATABASE_USER
-	utils.DATABASE_PASSWORD = DATABASE_PASSWORD
-	utils.DATABASE_DB_NAME = DATABASE_DB_NAME
-	utils.DYNAMO_MODE = DYNAMO_MODE
-	utils.AWS_DEFAULT_REGION = AWS_DEFAULT_REGION
-	utils.SESSION_KEY = SESSION_KEY
-
-	/*utils.PHOTOS_BUCKET = os.Getenv("PHOTOS_BUCKET")
-	utils.CSRF_SECRET = os.Getenv("CSRF_SECRET")
-
-	utils.DATABASE_HOST = os.Getenv("DATABASE_HOST")
-	utils.DATABASE_USER = os.Getenv("DATABASE_USER")
-	utils.DATABASE_PASSWORD = os.Getenv("DATABASE_PASSWORD")
-	utils.DATABASE_DB_NAME = os.Getenv("DATABASE_DB_NAME")
-
-	utils.DYNAMO_MODE = os.Getenv("DYNAMO_MODE")*/
-}
+		log.Fatalf("%v", err)
+	}
 
-func main() {
-	log.Println("Attempting to start server on port 80...")
+	logger, err := logging.New(cfg.LogFormat, cfg.LogLevel, os.Stderr)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	slog.SetDefault(logger)
 
-	server, err := server.NewServer()
+	shutdownTracing, err := tracing.Setup(cfg.TracingExporter, cfg.TracingEndpoint, cfg.TracingSampleRatio)
 	if err != nil {
-		log.Fatalf("server startup error: %v", err)
+		fatal("server startup error", err)
+	}
+
+	server, err := server.NewServer(cfg)
+	if err != nil {
+		fatal("server startup error", err)
+	}
+
+	httpServer := &http.Server{
+		Addr:         cfg.ListenAddr,
+		Handler:      server,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	if cfg.TLSEnabled() {
+		reloader, err := utils.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			fatal("server startup error", err)
+		}
+		httpServer.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("attempting to start server", "addr", cfg.ListenAddr, "tls", cfg.TLSEnabled())
+
+		if cfg.TLSEnabled() {
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("could not listen", err, "addr", cfg.ListenAddr)
+		}
+	case <-ctx.Done():
+		stop()
+		slog.Info("shutting down, draining connections", "grace_period", cfg.ShutdownTimeout.String())
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("graceful shutdown interrupted", "error", err)
+		}
+	}
+
+	if err := server.Close(); err != nil {
+		slog.Error("error to close stores", "error", err)
 	}
 
-	if err := http.ListenAndServe(":80", server); err != nil {
-		log.Fatalf("could not listen on port 80: %v", err)
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("error to flush traces", "error", err)
 	}
 }
+
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
+}
@@ -8,5 +8,56 @@ CREATE TABLE IF NOT EXISTS employee (
   location nvarchar(200) not null,
   job_title nvarchar(200) not null,
   badges nvarchar(200) not null,
-  created_datetime DATETIME DEFAULT now()
-);
\ No newline at end of file
+  email nvarchar(254) not null default '',
+  phones nvarchar(200) not null default '',
+  department nvarchar(200) not null default '',
+  manager_id int null,
+  start_date date null,
+  pronouns nvarchar(40) not null default '',
+  timezone varchar(64) not null default '',
+  bio text null,
+  handles json null,
+  custom_fields json null,
+  created_datetime DATETIME DEFAULT now(),
+  INDEX idx_employee_location (location),
+  INDEX idx_employee_job_title (job_title),
+  CONSTRAINT fk_employee_manager FOREIGN KEY (manager_id) REFERENCES employee(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS custom_field (
+  field_key varchar(64) not null primary key,
+  label nvarchar(200) not null,
+  field_type varchar(20) not null,
+  options json null,
+  required boolean not null default false,
+  visibility varchar(20) not null default 'public',
+  position int not null default 0
+);
+
+CREATE TABLE IF NOT EXISTS team (
+  id int not null auto_increment primary key,
+  name nvarchar(200) not null,
+  kind varchar(20) not null default 'team',
+  description text null,
+  lead_id int null,
+  CONSTRAINT fk_team_lead FOREIGN KEY (lead_id) REFERENCES employee(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS team_member (
+  team_id int not null,
+  employee_id int not null,
+  PRIMARY KEY (team_id, employee_id),
+  INDEX idx_team_member_employee (employee_id),
+  CONSTRAINT fk_team_member_team FOREIGN KEY (team_id) REFERENCES team(id) ON DELETE CASCADE,
+  CONSTRAINT fk_team_member_employee FOREIGN KEY (employee_id) REFERENCES employee(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS office (
+  id int not null auto_increment primary key,
+  name nvarchar(200) not null,
+  address text null,
+  timezone varchar(64) not null default '',
+  latitude double null,
+  longitude double null,
+  UNIQUE INDEX idx_office_name (name)
+);
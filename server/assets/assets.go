@@ -0,0 +1,240 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// brotliLevel trades a little size for startup time: the best level takes
+// seconds on the bundled fonts, level 9 a fraction of that.
+const brotliLevel = 9
+
+// fontTypes covers the extensions missing from the builtin mime table on
+// minimal images.
+var fontTypes = map[string]string{
+	".eot":   "application/vnd.ms-fontobject",
+	".ttf":   "font/ttf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// cssURL matches url(...) references inside stylesheets, with or without
+// quotes, so relative references can be pointed at the hashed names.
+var cssURL = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+type file struct {
+	name        string
+	contentType string
+	etag        string
+	content     []byte
+	gzip        []byte
+	brotli      []byte
+}
+
+// Assets serves the files of an fs.FS under content-hashed names, e.g.
+// css/site.css as css/site.1a2b3c4d5e.css, so they can be cached forever.
+// Compressible files are gzip and brotli encoded once at startup.
+type Assets struct {
+	hashed map[string]string
+	files  map[string]*file
+}
+
+func New(fsys fs.FS) (*Assets, error) {
+	a := &Assets{
+		hashed: map[string]string{},
+		files:  map[string]*file{},
+	}
+
+	var names, stylesheets []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if path.Ext(name) == ".css" {
+			stylesheets = append(stylesheets, name)
+		} else {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// stylesheets go last because their content, and so their hash, depends
+	// on the hashed names of the fonts and images they reference
+	for _, name := range append(names, stylesheets...) {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if path.Ext(name) == ".css" {
+			content = a.rewriteURLs(name, content)
+		}
+		if err := a.add(name, content); err != nil {
+			return nil, fmt.Errorf("error to prepare asset '%s': %v", name, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Assets) add(name string, content []byte) error {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])[:10]
+
+	ext := path.Ext(name)
+	hashedName := strings.TrimSuffix(name, ext) + "." + hash + ext
+
+	f := &file{
+		name:        name,
+		contentType: contentType(ext),
+		etag:        `"` + hash + `"`,
+		content:     content,
+	}
+
+	if compressible(f.contentType) {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		gz.Write(content)
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		if buf.Len() < len(content) {
+			f.gzip = buf.Bytes()
+		}
+
+		buf = bytes.Buffer{}
+		br := brotli.NewWriterLevel(&buf, brotliLevel)
+		br.Write(content)
+		if err := br.Close(); err != nil {
+			return err
+		}
+		if buf.Len() < len(content) {
+			f.brotli = buf.Bytes()
+		}
+	}
+
+	a.hashed[name] = hashedName
+	a.files[hashedName] = f
+
+	return nil
+}
+
+func (a *Assets) rewriteURLs(name string, content []byte) []byte {
+	dir := path.Dir(name)
+
+	return cssURL.ReplaceAllFunc(content, func(match []byte) []byte {
+		parts := cssURL.FindSubmatch(match)
+		ref := string(parts[2])
+
+		target := ref
+		suffix := ""
+		if i := strings.IndexAny(ref, "?#"); i >= 0 {
+			target, suffix = ref[:i], ref[i:]
+		}
+		if strings.Contains(target, ":") || strings.HasPrefix(target, "/") {
+			return match
+		}
+
+		hashedName, exist := a.hashed[path.Join(dir, target)]
+		if !exist {
+			return match
+		}
+
+		rel := path.Join(path.Dir(target), path.Base(hashedName))
+		return []byte("url(" + string(parts[1]) + rel + suffix + string(parts[3]) + ")")
+	})
+}
+
+// Path returns the hashed name of an asset, e.g. for the src of a script.
+func (a *Assets) Path(name string) (string, error) {
+	hashedName, exist := a.hashed[name]
+	if !exist {
+		return "", fmt.Errorf("asset '%s' not found", name)
+	}
+	return hashedName, nil
+}
+
+// ServeHTTP expects the asset name as the request path, so it is meant to be
+// mounted behind http.StripPrefix. Hashed names are immutable; plain names
+// are still served but must be revalidated.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+
+	cacheControl := "public, max-age=31536000, immutable"
+	f, exist := a.files[name]
+	if !exist {
+		hashedName, plain := a.hashed[name]
+		if !plain {
+			http.NotFound(w, r)
+			return
+		}
+		f = a.files[hashedName]
+		cacheControl = "no-cache"
+	}
+
+	content, etag := f.content, f.etag
+	header := w.Header()
+	if f.gzip != nil || f.brotli != nil {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	switch {
+	case f.brotli != nil && acceptsEncoding(r, "br"):
+		content, etag = f.brotli, strings.TrimSuffix(etag, `"`)+`-br"`
+		header.Set("Content-Encoding", "br")
+	case f.gzip != nil && acceptsEncoding(r, "gzip"):
+		content, etag = f.gzip, strings.TrimSuffix(etag, `"`)+`-gzip"`
+		header.Set("Content-Encoding", "gzip")
+	}
+
+	header.Set("Content-Type", f.contentType)
+	header.Set("Cache-Control", cacheControl)
+	header.Set("ETag", etag)
+	http.ServeContent(w, r, f.name, time.Time{}, bytes.NewReader(content))
+}
+
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(value, ",") {
+			token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if !strings.EqualFold(strings.TrimSpace(token), encoding) {
+				continue
+			}
+			q := strings.ReplaceAll(params, " ", "")
+			return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+		}
+	}
+	return false
+}
+
+func contentType(ext string) string {
+	if t, exist := fontTypes[ext]; exist {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch mediaType {
+	case "application/javascript", "text/javascript", "image/svg+xml", "font/ttf", "application/vnd.ms-fontobject":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/")
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type stringValue struct {
+	p *string
+}
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+func (v stringValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+type durationValue struct {
+	p *time.Duration
+}
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("expected a duration like '30s' or '2m'")
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+type floatValue struct {
+	p *float64
+}
+
+func (v floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("expected a number like '0.25'")
+	}
+	*v.p = f
+	return nil
+}
+
+func (v floatValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v.p, 'g', -1, 64)
+}
+
+type intValue struct {
+	p *int
+}
+
+func (v intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("expected a whole number like '4000'")
+	}
+	*v.p = n
+	return nil
+}
+
+func (v intValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strconv.Itoa(*v.p)
+}
+
+// sizeValue reads a number of bytes, with an optional KB, MB or GB unit of
+// 1024 of the previous one, e.g. "5MB".
+type sizeValue struct {
+	p *int64
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+func (v sizeValue) Set(s string) error {
+	number, unit := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n > (1<<62)/unit {
+		return fmt.Errorf("expected a size like '5MB' or '512KB'")
+	}
+	*v.p = n * unit
+	return nil
+}
+
+func (v sizeValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	for _, u := range sizeUnits {
+		if *v.p != 0 && *v.p%u.size == 0 {
+			return strconv.FormatInt(*v.p/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(*v.p, 10)
+}
+
+// listValue reads comma-separated values, e.g. "10.0.0.0/8, 172.16.0.0/12".
+type listValue struct {
+	p *[]string
+}
+
+func (v listValue) Set(s string) error {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*v.p = list
+	return nil
+}
+
+func (v listValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strings.Join(*v.p, ",")
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+	"github.com/moura1001/aws-employee-directory-application/server/store"
+)
+
+// newTestServer returns a server over an in-memory store, enough for the
+// helpers that only read and write employees.
+func newTestServer() (*Server, *store.InMemoryStore) {
+	db := store.NewInMemoryStore()
+	return &Server{store: db, backends: map[string]string{"employees": "memory"}}, db
+}
+
+func addEmployee(t *testing.T, db *store.InMemoryStore, name, managerId string) string {
+	t.Helper()
+	id, err := db.AddEmployee("", name, "Seattle", "Engineer", nil, model.Profile{ManagerId: managerId})
+	if err != nil {
+		t.Fatalf("AddEmployee(%q) = %v", name, err)
+	}
+	return id
+}
+
+func TestValidateManager(t *testing.T) {
+	server, db := newTestServer()
+	ana := addEmployee(t, db, "Ana", "")
+	bea := addEmployee(t, db, "Bea", ana)
+	cid := addEmployee(t, db, "Cid", bea)
+
+	tests := []struct {
+		name       string
+		employeeId string
+		managerId  string
+		valid      bool
+	}{
+		{"no manager", ana, "", true},
+		{"manager above", cid, ana, true},
+		{"new employee", "", cid, true},
+		{"direct report", ana, bea, false},
+		{"report of a report", ana, cid, false},
+		{"missing manager", bea, "42", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := model.NewForm()
+			form.EmployeeId.Data = tt.employeeId
+			form.Manager.Data = tt.managerId
+
+			err := server.validateManager(httptest.NewRequest("POST", "/save", nil), &form)
+			if err != nil {
+				t.Fatalf("validateManager() = %v", err)
+			}
+			if form.Manager.Valid() != tt.valid {
+				t.Errorf("validateManager() errors = %q, want valid %v", form.Manager.Errors, tt.valid)
+			}
+		})
+	}
+}
+
+func TestReportingLine(t *testing.T) {
+	server, db := newTestServer()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	ids := []string{addEmployee(t, db, "Top", "")}
+	for i := 1; i <= maxReportingLine+10; i++ {
+		ids = append(ids, addEmployee(t, db, "Level "+strconv.Itoa(i), ids[i-1]))
+	}
+
+	tests := []struct {
+		name     string
+		level    int
+		length   int
+		cutShort bool
+	}{
+		{"top of the organization", 0, 0, false},
+		{"reaches the top", 3, 3, false},
+		{"at the limit", maxReportingLine, maxReportingLine, false},
+		{"over the limit", maxReportingLine + 10, maxReportingLine, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			employee, err := db.LoadEmployee(ids[tt.level])
+			if err != nil {
+				t.Fatalf("LoadEmployee() = %v", err)
+			}
+
+			line, cutShort := server.reportingLine(r, employee)
+			if len(line) != tt.length || cutShort != tt.cutShort {
+				t.Errorf("reportingLine() = %d managers, cut short %v, want %d, %v", len(line), cutShort, tt.length, tt.cutShort)
+			}
+			if len(line) > 0 && line[0].Id != employee.ManagerId {
+				t.Errorf("reportingLine() starts at %s, want the direct manager %s", line[0].Id, employee.ManagerId)
+			}
+		})
+	}
+}
+
+func TestReportingLineStopsAtCycles(t *testing.T) {
+	server, db := newTestServer()
+	ana := addEmployee(t, db, "Ana", "")
+	bea := addEmployee(t, db, "Bea", ana)
+	if err := db.UpdateEmployee(ana, "", "Ana", "Seattle", "Engineer", nil, model.Profile{ManagerId: bea}); err != nil {
+		t.Fatalf("UpdateEmployee() = %v", err)
+	}
+	employee, _ := db.LoadEmployee(ana)
+
+	line, cutShort := server.reportingLine(httptest.NewRequest("GET", "/", nil), employee)
+	if len(line) != 1 || line[0].Id != bea || !cutShort {
+		t.Errorf("reportingLine() = %d managers, cut short %v, want only Bea and cut short", len(line), cutShort)
+	}
+}
+
+func TestValidId(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"42", true},
+		{"3f2b8a1e-6c1d-4e2b-9a7f-0c5d2e8b1a64", true},
+		{"", false},
+		{"schema#custom_fields", false},
+		{"team#7", false},
+		{"42#badge#mentor", false},
+	}
+
+	for _, tt := range tests {
+		if got := validId(tt.id); got != tt.valid {
+			t.Errorf("validId(%q) = %v, want %v", tt.id, got, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moura1001/aws-employee-directory-application/server/config"
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+	"github.com/moura1001/aws-employee-directory-application/server/store"
+)
+
+func TestSignPhotoLeavesEmployeeAsIs(t *testing.T) {
+	server, _ := newTestServer()
+	server.config = &config.Config{PhotoURLs: "proxy"}
+	server.router = mux.NewRouter()
+	server.router.HandleFunc("/photos/{objectKey:.+}", server.photo).Name("photo")
+	server.router.HandleFunc("/avatars/{employeeId}/{rendition}.svg", server.avatar).Name("avatar")
+	r := httptest.NewRequest("GET", "/", nil)
+
+	tests := []struct {
+		name     string
+		employee model.Employee
+		want     string
+	}{
+		{
+			"photo",
+			model.Employee{Id: "1", FullName: "Ana Lima", Photo: &model.Photo{ObjectKey: model.PhotoKey("1", "v1", model.RenditionProfile)}},
+			"photos/employee_pic/1/v1/profile.png",
+		},
+		{"no photo", model.Employee{Id: "2", FullName: "Bea Souza"}, "avatars/2/profile.svg?initials=BS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			employee := tt.employee
+			before := employee
+			if employee.Photo != nil {
+				photo := *employee.Photo
+				before.Photo = &photo
+			}
+
+			signed := server.signPhoto(r, &employee)
+
+			if got := signed.Photo.SignedUrl; got != tt.want {
+				t.Errorf("SignedUrl = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(employee, before) {
+				t.Errorf("signPhoto() changed the employee to %+v, want %+v", employee, before)
+			}
+		})
+	}
+}
+
+func TestPhotoServesOnlyPhotoKeys(t *testing.T) {
+	dir := t.TempDir()
+	server, _ := newTestServer()
+	server.photoStore = store.NewFileStore(dir)
+	server.router = mux.NewRouter()
+	server.router.HandleFunc("/photos/{objectKey:.+}", server.photo).Name("photo")
+
+	key := model.PhotoKey("1", "df73945ec73f3001", model.RenditionProfile)
+	for _, name := range []string{key, "employee_pic/1/df73945ec73f3001/notes.txt", "secrets.txt", "employee_pic/1.jpg"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		ifNoneMatch string
+		status      int
+	}{
+		{"photo", key, "", http.StatusOK},
+		{"photo cached by the browser", key, `"df73945ec73f3001/profile.png"`, http.StatusNotModified},
+		{"other file of a version", "employee_pic/1/df73945ec73f3001/notes.txt", "", http.StatusNotFound},
+		{"other object", "secrets.txt", "", http.StatusNotFound},
+		{"unversioned photo", "employee_pic/1.jpg", "", http.StatusNotFound},
+		{"not a key, with an etag", "secrets.txt", `"df73945ec73f3001/profile.png"`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/photos/"+tt.key, nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("GET /photos/%s = %d, want %d", tt.key, w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -1,27 +1,64 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"image"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
+	"github.com/moura1001/aws-employee-directory-application/server/assets"
+	"github.com/moura1001/aws-employee-directory-application/server/config"
+	"github.com/moura1001/aws-employee-directory-application/server/health"
+	"github.com/moura1001/aws-employee-directory-application/server/logging"
+	"github.com/moura1001/aws-employee-directory-application/server/metrics"
 	"github.com/moura1001/aws-employee-directory-application/server/model"
+	"github.com/moura1001/aws-employee-directory-application/server/proxy"
+	"github.com/moura1001/aws-employee-directory-application/server/security"
 	"github.com/moura1001/aws-employee-directory-application/server/store"
+	"github.com/moura1001/aws-employee-directory-application/server/tracing"
 	"github.com/moura1001/aws-employee-directory-application/server/utils"
+	"github.com/moura1001/aws-employee-directory-application/server/view"
+	"github.com/moura1001/aws-employee-directory-application/static"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Server struct {
-	store    store.EmployeeStore
-	s3Client store.S3Store
+	config     *config.Config
+	store      store.EmployeeStore
+	photoStore store.PhotoStore
+	backends   map[string]string
+	metrics    *metrics.Metrics
+	health     *health.Checker
+	templates  *view.Templates
+	router     *mux.Router
 	http.Handler
+	photoLimits      model.PhotoLimits
 	maxBytesReader   int64
 	availabilityZone string
 	instanceId       string
@@ -30,52 +67,245 @@ type Server struct {
 	flashTemplate    string
 }
 
-func NewServer() (*Server, error) {
+// flash is a message shown once, on the next page rendered for the user.
+type flash struct {
+	Severity string
+	Message  string
+}
+
+const (
+	flashSuccess = "success"
+	flashError   = "error"
+)
+
+// AlertClass maps the severity to the Bootstrap alert modifier.
+func (f flash) AlertClass() string {
+	if f.Severity == flashError {
+		return "danger"
+	}
+	return f.Severity
+}
+
+func init() {
+	// flashes are stored in the session cookie, which is gob encoded
+	gob.Register(flash{})
+}
+
+var sameSite = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+var csrfSameSite = map[string]csrf.SameSiteMode{
+	"lax":    csrf.SameSiteLaxMode,
+	"strict": csrf.SameSiteStrictMode,
+	"none":   csrf.SameSiteNoneMode,
+}
+
+func NewServer(cfg *config.Config) (*Server, error) {
 	server := new(Server)
+	server.config = cfg
 
-	server.session = sessions.NewCookieStore([]byte(utils.SESSION_KEY))
+	server.session = sessions.NewCookieStore([]byte(cfg.SessionKey))
+	server.session.Options.HttpOnly = true
+	server.session.Options.Secure = cfg.SecureCookies()
+	server.session.Options.SameSite = sameSite[cfg.CookieSameSite]
 	server.sessionName = "employee-session"
 	server.flashTemplate = "flashed_messages"
 
 	if err := server.setInstanceDocumentInfo(); err != nil {
-		log.Printf(" * Instance metadata not available. Details: '%s'\n", err)
+		slog.Warn("instance metadata not available", "error", err)
 		server.availabilityZone = "us-fake-1a"
 		server.instanceId = "i-fakeabc"
 	}
 
-	if utils.DYNAMO_MODE == "on" {
-		server.store = store.NewDynamoStore()
-	} else {
-		server.store = store.NewMysqlStore()
+	server.metrics = metrics.New()
+
+	employeeStore, err := store.OpenEmployeeStore(cfg.EmployeeStore)
+	if err != nil {
+		return nil, err
 	}
+	server.backends = map[string]string{
+		"employees": store.Backend(cfg.EmployeeStore),
+		"photos":    store.Backend(cfg.PhotoStore),
+	}
+
+	if migrator, ok := employeeStore.(store.Migrator); ok {
+		// the store serves requests while it migrates, so startup does not
+		// wait for it, and a failure is retried on the next start
+		go func() {
+			if err := migrator.Migrate(); err != nil {
+				slog.Error("error to migrate employee store", "error", err)
+			}
+		}()
+	}
+
+	server.store = server.metrics.EmployeeStore(server.backends["employees"], employeeStore)
+	server.metrics.RegisterDirectoryGauges(server.store, time.Minute)
+
+	photoStore, err := store.OpenPhotoStore(cfg.PhotoStore)
+	if err != nil {
+		return nil, err
+	}
+	// the cache wraps the metrics, which then count only the urls signed
+	server.photoStore = store.CachePresignedURLs(server.metrics.PhotoStore(server.backends["photos"], photoStore))
 
-	server.s3Client = store.NewS3Store()
+	server.health = health.NewChecker(cfg.HealthCheckInterval, cfg.HealthCheckTimeout)
+	server.health.Add("database", server.store.Ping)
+	server.health.Add("photos", server.photoStore.Ping)
+	server.health.Start()
 
 	router := mux.NewRouter()
-	router.HandleFunc("/", server.home).Methods("GET")
-	router.HandleFunc("/add", server.add).Methods("GET")
-	router.HandleFunc("/edit/{employeeId}", server.edit).Methods("GET")
-	router.HandleFunc("/save", server.save).Methods("POST")
-	router.HandleFunc("/employee/{employeeId}", server.view).Methods("GET")
-	router.HandleFunc("/delete/{employeeId}", server.delete).Methods("GET")
-	router.HandleFunc("/info", server.info).Methods("GET")
-	router.HandleFunc("/info/stress_cpu/{seconds}", server.stress).Methods("GET")
-	router.HandleFunc("/monitor", server.monitor).Methods("GET")
-
-	server.Handler = csrf.Protect(
-		[]byte(utils.CSRF_SECRET),
+	server.router = router
+	middleware := []mux.MiddlewareFunc{
+		otelmux.Middleware(tracing.ServiceName),
+		logging.RequestID(slog.Default()),
+		logging.AccessLog,
+		server.metrics.Middleware,
+	}
+	router.Use(middleware...)
+	// mux runs its middleware only for matched routes, so the fallback
+	// handlers are wrapped too to be traced, logged and counted
+	router.NotFoundHandler = withMiddleware(http.NotFoundHandler(), middleware)
+	router.MethodNotAllowedHandler = withMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}), middleware)
+	router.HandleFunc("/", server.home).Methods("GET").Name("home")
+	router.HandleFunc("/add", server.add).Methods("GET").Name("add")
+	router.HandleFunc("/edit/{employeeId}", server.edit).Methods("GET").Name("edit")
+	router.HandleFunc("/save", server.save).Methods("POST").Name("save")
+	router.HandleFunc("/employee/{employeeId}", server.view).Methods("GET").Name("view")
+	router.HandleFunc("/delete/{employeeId}", server.delete).Methods("POST").Name("delete")
+	router.HandleFunc("/teams", server.teams).Methods("GET").Name("teams")
+	router.HandleFunc("/teams/add", server.addTeam).Methods("GET").Name("add_team")
+	router.HandleFunc("/teams/save", server.saveTeam).Methods("POST").Name("save_team")
+	router.HandleFunc("/teams/{teamId}", server.team).Methods("GET").Name("team")
+	router.HandleFunc("/teams/{teamId}/edit", server.editTeam).Methods("GET").Name("edit_team")
+	router.HandleFunc("/teams/{teamId}/delete", server.deleteTeam).Methods("POST").Name("delete_team")
+	router.HandleFunc("/offices", server.offices).Methods("GET").Name("offices")
+	router.HandleFunc("/offices/{officeId}", server.office).Methods("GET").Name("office")
+	router.HandleFunc("/org", server.org).Methods("GET").Name("org")
+	router.HandleFunc("/api/employees/{employeeId}/subtree", server.subtree).Methods("GET").Name("subtree")
+	router.HandleFunc("/info", server.info).Methods("GET").Name("info")
+	router.HandleFunc("/info/stress_cpu/{seconds}", server.stress).Methods("POST").Name("stress")
+	router.HandleFunc("/monitor", server.monitor).Methods("GET").Name("monitor")
+	router.HandleFunc("/healthz", server.healthz).Methods("GET").Name("healthz")
+	router.HandleFunc("/readyz", server.readyz).Methods("GET").Name("readyz")
+	router.Handle("/metrics", server.metrics.Handler()).Methods("GET").Name("metrics")
+	// the route reads the store for anyone, so it exists only when pages
+	// link to it; presigned S3 urls keep the bucket private
+	if cfg.PhotoURLs == "proxy" || server.backends["photos"] == "file" {
+		router.HandleFunc("/photos/{objectKey:.+}", server.photo).Methods("GET").Name("photo")
+	}
+	router.HandleFunc("/avatars/{employeeId}/{rendition}.svg", server.avatar).Methods("GET").Name("avatar")
+	router.HandleFunc("/admin/fields", server.adminOnly(server.customFields)).Methods("GET").Name("custom_fields")
+	router.HandleFunc("/admin/fields", server.adminOnly(server.saveCustomField)).Methods("POST").Name("save_custom_field")
+	router.HandleFunc("/admin/fields/{key}", server.adminOnly(server.customFields)).Methods("GET").Name("edit_custom_field")
+	router.HandleFunc("/admin/fields/{key}/delete", server.adminOnly(server.deleteCustomField)).Methods("POST").Name("delete_custom_field")
+	router.HandleFunc("/admin/offices", server.adminOnly(server.adminOffices)).Methods("GET").Name("admin_offices")
+	router.HandleFunc("/admin/offices", server.adminOnly(server.saveOffice)).Methods("POST").Name("save_office")
+	router.HandleFunc("/admin/offices/{officeId}", server.adminOnly(server.adminOffices)).Methods("GET").Name("edit_office")
+	router.HandleFunc("/admin/offices/{officeId}/delete", server.adminOnly(server.deleteOffice)).Methods("POST").Name("delete_office")
+	router.HandleFunc("/admin/locations", server.adminOnly(server.locations)).Methods("GET").Name("locations")
+	router.HandleFunc("/admin/locations/merge", server.adminOnly(server.mergeLocations)).Methods("POST").Name("merge_locations")
+
+	assetFS, err := fs.Sub(static.Files, "assets")
+	if err != nil {
+		return nil, err
+	}
+	staticAssets, err := assets.New(assetFS)
+	if err != nil {
+		return nil, err
+	}
+	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", staticAssets)).Methods("GET", "HEAD").Name("static")
+
+	var templateFS fs.FS
+	if cfg.TemplatesDir != "" {
+		templateFS = os.DirFS(cfg.TemplatesDir)
+	} else if templateFS, err = fs.Sub(static.Files, "templates"); err != nil {
+		return nil, err
+	}
+	server.templates, err = view.New(templateFS, templateFuncs(router, staticAssets), cfg.TemplatesDir != "")
+	if err != nil {
+		return nil, err
+	}
+
+	protected := server.limitUploads(csrf.Protect(
+		[]byte(cfg.CsrfSecret),
 		csrf.Path("/"),
-		csrf.Secure(false),
-	)(router)
+		csrf.Secure(cfg.SecureCookies()),
+		csrf.SameSite(csrfSameSite[cfg.CookieSameSite]),
+	)(router))
+	trusted, err := cfg.TrustedProxyNetworks()
+	if err != nil {
+		return nil, err
+	}
+	var baseURL *url.URL
+	if cfg.BaseURL != "" {
+		if baseURL, err = url.Parse(cfg.BaseURL); err != nil {
+			return nil, err
+		}
+	}
 
-	server.maxBytesReader = 1<<20 + 1024
+	server.Handler = proxy.Forwarded(trusted, baseURL)(security.Headers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// assets are public, and the csrf cookie and Vary: Cookie it adds
+		// would keep browsers and CDNs from caching them
+		if strings.HasPrefix(r.URL.Path, "/static/") {
+			router.ServeHTTP(w, r)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	})))
+
+	server.photoLimits = model.PhotoLimits{
+		Formats:   cfg.PhotoFormats,
+		MaxBytes:  cfg.PhotoMaxBytes,
+		MaxWidth:  cfg.PhotoMaxWidth,
+		MaxHeight: cfg.PhotoMaxHeight,
+	}
+	// room for the other fields and the multipart boundaries too
+	server.maxBytesReader = cfg.PhotoMaxBytes + 64<<10
 
 	return server, nil
 }
 
+// validId tells whether id, taken from the url, can be the id of a record.
+// The stores only generate numbers and uuids, and the DynamoDB table keeps
+// its schema, team and office items under keys with '#', which must not be
+// loaded or deleted as employees.
+func validId(id string) bool {
+	return id != "" && model.Identifier()(id) == nil
+}
+
+// withMiddleware wraps handler the way mux wraps matched routes, the first
+// middleware being the outermost.
+func withMiddleware(handler http.Handler, middleware []mux.MiddlewareFunc) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Close releases the store clients that hold connections open.
+func (server *Server) Close() error {
+	server.health.Stop()
+
+	var err error
+	for _, s := range []interface{}{server.store, server.photoStore} {
+		if closer, ok := s.(io.Closer); ok {
+			if closeErr := closer.Close(); closeErr != nil {
+				err = closeErr
+			}
+		}
+	}
+
+	return err
+}
+
 func (server *Server) setInstanceDocumentInfo() error {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), func(opts *config.LoadOptions) error {
-		opts.Region = utils.AWS_DEFAULT_REGION
+	cfg, err := awsconfig.LoadDefaultConfig(context.TODO(), func(opts *awsconfig.LoadOptions) error {
+		opts.Region = server.config.AwsRegion
 		return nil
 	})
 	if err != nil {
@@ -95,189 +325,427 @@ func (server *Server) setInstanceDocumentInfo() error {
 	return nil
 }
 
-func urlFor(host string, endpoint string) string {
-	return "http://" + host + endpoint
+// serverError logs err with the request id before answering with a 500, so
+// the message a user reports can be matched to the log entry.
+func (server *Server) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("request failed", "error", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
 }
 
-func (server *Server) home(w http.ResponseWriter, r *http.Request) {
-	session, _ := server.session.Get(r, server.sessionName)
-	flashedMessages, _ := session.Values[server.flashTemplate].([]string)
-	if len(flashedMessages) > 0 {
-		session.Values[server.flashTemplate] = nil
-		session.Save(r, w)
+func (server *Server) employees(r *http.Request) store.EmployeeStore {
+	return tracing.EmployeeStore(r.Context(), server.backends["employees"], server.store)
+}
+
+func (server *Server) photos(r *http.Request) store.PhotoStore {
+	return tracing.PhotoStore(r.Context(), server.backends["photos"], server.photoStore)
+}
+
+// render writes the page only once it executed completely, so a template
+// error turns into a 500 instead of a half-rendered page. The flashes shown
+// leave the session only then, so they aren't lost with the failed page.
+func (server *Server) render(w http.ResponseWriter, r *http.Request, code int, name string, data map[string]interface{}) {
+	var popped []flash
+	if _, set := data[server.flashTemplate]; !set {
+		popped = server.pendingFlashes(r)
+		data[server.flashTemplate] = popped
 	}
 
-	employees, err := server.store.ListEmployees()
+	_, span := tracing.Start(r.Context(), "template.render", attribute.String("template.name", name))
+
+	data["csp_nonce"] = security.NonceFromContext(r.Context())
+	data["base_path"] = proxy.FromContext(r.Context()).Prefix + "/"
+	data["csrf_token"] = csrf.Token(r)
+
+	var buf bytes.Buffer
+	err := server.templates.Render(&buf, name, data)
+	tracing.End(span, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		server.serverError(w, r, fmt.Errorf("error to render template '%s': %v", name, err))
 		return
 	}
 
-	if len(employees) == 0 {
-		url := urlFor(r.Host, "/add")
-		templateStr := fmt.Sprintf(`
-			{{template "main" .}}
-			{{define "head"}}
-			Employee Directory - Home
-			<a class="btn btn-primary float-right" href="%s">Add</a>
-			{{end}}
-			{{define "body"}}Empty{{end}}
-		`, url)
+	if len(popped) > 0 {
+		server.saveSession(w, r)
+	}
 
-		t, _ := template.New("home").Parse(templateStr)
-		t, err := t.ParseFiles("./static/templates/main.html")
-		if err == nil {
-			err = t.Execute(w, map[string]interface{}{
-				server.flashTemplate: flashedMessages,
-			})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
+
+func (server *Server) addFlash(w http.ResponseWriter, r *http.Request, severity, message string) {
+	session, _ := server.session.Get(r, server.sessionName)
+	session.AddFlash(flash{Severity: severity, Message: message}, server.flashTemplate)
+	server.saveSession(w, r)
+}
+
+func (server *Server) saveSession(w http.ResponseWriter, r *http.Request) {
+	session, _ := server.session.Get(r, server.sessionName)
+	if err := session.Save(r, w); err != nil {
+		logging.FromContext(r.Context()).Error("error to save session", "error", err)
+	}
+}
+
+// pendingFlashes returns the pending flashes and removes them from the
+// session of the request; the cookie keeps them until the session is saved.
+func (server *Server) pendingFlashes(r *http.Request) []flash {
+	session, _ := server.session.Get(r, server.sessionName)
+
+	// sessions from before the severities kept a []string under the same key
+	if _, ok := session.Values[server.flashTemplate].([]interface{}); !ok {
+		delete(session.Values, server.flashTemplate)
+	}
+
+	pending := session.Flashes(server.flashTemplate)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	flashes := make([]flash, 0, len(pending))
+	for _, f := range pending {
+		if f, ok := f.(flash); ok {
+			flashes = append(flashes, f)
+		}
+	}
+	return flashes
+}
+
+// templateFuncs exposes the named routes and the static assets to the
+// templates, e.g. {{ url "view" "employeeId" .Id }} and
+// {{ asset "css/bootstrap.min.css" }}. The urls are relative to the
+// <base href> of the layout, which carries the path prefix of the request.
+func templateFuncs(router *mux.Router, staticAssets *assets.Assets) template.FuncMap {
+	return template.FuncMap{
+		"asset": func(name string) (string, error) {
+			hashedName, err := staticAssets.Path(name)
 			if err != nil {
-				fmt.Fprintf(w, "error to execute template: %+v\n", err)
+				return "", err
 			}
-		}
+			u, err := router.Get("static").URLPath()
+			if err != nil {
+				return "", err
+			}
+			return relative(u.Path + hashedName), nil
+		},
+		"url": func(name string, pairs ...string) (string, error) {
+			route := router.Get(name)
+			if route == nil {
+				return "", fmt.Errorf("no route named '%s'", name)
+			}
+			u, err := route.URLPath(pairs...)
+			if err != nil {
+				return "", err
+			}
+			return relative(u.Path), nil
+		},
+	}
+}
+
+func relative(path string) string {
+	if path = strings.TrimPrefix(path, "/"); path == "" {
+		return "./"
+	}
+	return path
+}
+
+// redirect sends the client to a named route with 303 See Other, so the
+// browser follows with a GET and never caches the redirect. The location is
+// absolute and built from the origin the client used, so the https scheme
+// and the path prefix of a load balancer are kept.
+func (server *Server) redirect(w http.ResponseWriter, r *http.Request, name string, pairs ...string) {
+	u, err := server.router.Get(name).URLPath(pairs...)
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, proxy.FromContext(r.Context()).URL(u.Path), http.StatusSeeOther)
+}
+
+func (server *Server) home(w http.ResponseWriter, r *http.Request) {
+	employees, err := server.listEmployees(r)
+	if err != nil {
+		server.serverError(w, r, err)
 		return
+	}
+	employees = server.signPhotos(r, employees)
+
+	server.render(w, r, http.StatusOK, "home", map[string]interface{}{
+		"employees": employees,
+		"badges":    model.Badges,
+		"teams":     server.teamOptions(r),
+		"team":      r.URL.Query().Get("team"),
+	})
+}
+
+// signPhotos returns the employees with the urls of their photos, see
+// signPhoto.
+func (server *Server) signPhotos(r *http.Request, employees []*model.Employee) []*model.Employee {
+	signed := make([]*model.Employee, len(employees))
+	for i, employee := range employees {
+		signed[i] = server.signPhoto(r, employee)
+	}
+	return signed
+}
+
+// signPhoto returns a copy of employee whose photo has the url of every
+// rendition, SignedUrl being the profile one. Employees without a photo get
+// their generated avatar instead. The urls only hold for this request, so
+// employee itself, which may come from a store, is left as is.
+func (server *Server) signPhoto(r *http.Request, employee *model.Employee) *model.Employee {
+	photo := &model.Photo{}
+	if employee.Photo != nil {
+		photo.ObjectKey = employee.Photo.ObjectKey
+	}
+
+	if photo.ObjectKey == "" {
+		server.setAvatar(r, employee, photo)
 	} else {
-		for _, employee := range employees {
-			if employee.Photo.ObjectKey != "" {
-				url, err := server.s3Client.GeneratePresignedURL(employee.Photo.ObjectKey)
-				if err == nil {
-					employee.Photo.SignedUrl = url
-				} else {
-					employee.Photo.SignedUrl = err.Error()
-				}
+		photo.Renditions = map[string]string{}
+		for _, name := range []string{model.RenditionThumbnail, model.RenditionProfile, model.RenditionOriginal} {
+			url, err := server.photoURL(r, photo.RenditionKey(name))
+			if err != nil {
+				url = err.Error()
 			}
+			photo.Renditions[name] = url
 		}
+		photo.SignedUrl = photo.Renditions[model.RenditionProfile]
 	}
 
-	urlAdd := urlFor(r.Host, "/add")
-	urlDelete := urlFor(r.Host, "/delete")
-	urlView := urlFor(r.Host, "/employee")
-
-	templateStr := fmt.Sprintf(`
-	{{ template "main" .}}
-	{{ define "head" }}
-	Employee Directory - Home
-	<a class="btn btn-primary float-right" href="%s">Add</a>
-	{{ end }}
-	{{ define "body" }}
-		{{  if not .employees }}<h4>Empty Directory</h4>{{ end }}
-
-		<table class="table table-bordered">
-		  <tbody>
-		  	{{ $badges := .badges }}
-			{{ range $employee := .employees }}
-				<tr>
-				<td width="100">{{ if $employee.Photo.SignedUrl }}
-				<img width="50" src="{{$employee.Photo.SignedUrl}}" /><br/>
-				{{ end }}
-				<a href="%s/{{$employee.Id}}"><span class="fa fa-remove" aria-hidden="true"></span> delete</a>
-				</td>
-				<td><a href="%s/{{$employee.Id}}">{{$employee.FullName}}</a>
-				{{ range $key, $badge := $badges }}
-				{{ if $employee.HasBadge $key }}
-				<i class="fa fa-{{$key}}" title="{{$badge}}"></i>
-				{{ end }}
-				{{ end }}
-				<br/>
-				<small>{{$employee.Location}}</small>
-				</td>
-				</tr>
-			{{ end }}
-
-		  </tbody>
-		</table>
-
-	{{ end }}
-	`, urlAdd, urlDelete, urlView)
-
-	t, _ := template.New("home").Parse(templateStr)
-	t, err = t.ParseFiles("./static/templates/main.html")
-	if err == nil {
-		err = t.Execute(w, map[string]interface{}{
-			"employees":          employees,
-			"badges":             model.Badges,
-			server.flashTemplate: flashedMessages,
-		})
+	signed := *employee
+	signed.Photo = photo
+	return &signed
+}
+
+// setAvatar points the renditions of photo to the avatar of employee.
+func (server *Server) setAvatar(r *http.Request, employee *model.Employee, photo *model.Photo) {
+	photo.Renditions = map[string]string{}
+	for _, rendition := range model.PhotoRenditions {
+		u, err := server.router.Get("avatar").URLPath("employeeId", employee.Id, "rendition", rendition.Name)
 		if err != nil {
-			fmt.Fprintf(w, "error to execute template: %+v\n", err)
+			logging.FromContext(r.Context()).Error("error to build avatar url", "employee_id", employee.Id, "error", err)
+			return
 		}
+		photo.Renditions[rendition.Name] = relative(u.Path) + "?" + url.Values{"initials": {employee.Initials()}}.Encode()
 	}
+	photo.SignedUrl = photo.Renditions[model.RenditionProfile]
 }
 
-func (server *Server) add(w http.ResponseWriter, r *http.Request) {
-	t, err := template.ParseFiles("./static/templates/view-edit.html", "./static/templates/main.html")
-	if err == nil {
-		err = t.Execute(w, map[string]interface{}{
-			"form":       model.NewForm(),
-			"badges":     model.Badges,
-			"url_save":   urlFor(r.Host, "/save"),
-			"csrf_token": csrf.Token(r),
-		})
+// photoURL links to an object of the photo store, either presigned by the
+// store or through the photo route, as PHOTO_URLS says.
+func (server *Server) photoURL(r *http.Request, objectKey string) (string, error) {
+	// the route serves versioned photos only, so the older ones are linked
+	// to the store even in proxy mode
+	if server.config.PhotoURLs == "proxy" && model.IsPhotoKey(objectKey) {
+		u, err := server.router.Get("photo").URLPath("objectKey", objectKey)
 		if err != nil {
-			fmt.Fprintf(w, "error to execute template: %+v\n", err)
+			return "", err
 		}
+		return relative(u.Path), nil
 	}
+
+	url, _, err := server.photos(r).GeneratePresignedURL(objectKey, server.config.PhotoURLExpiry)
+	return url, err
 }
 
-func (server *Server) edit(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
+// uploadPhoto stores the picture, as cleaned by the form, and its scaled
+// renditions, cut to crop first, and returns the key to save on the
+// employee. The keys are versioned by a hash of the picture and the crop.
+func (server *Server) uploadPhoto(r *http.Request, employeeId string, picture []byte, crop image.Rectangle) (string, error) {
+	hash := sha256.New()
+	hash.Write(picture)
+	fmt.Fprint(hash, crop)
+	version := hex.EncodeToString(hash.Sum(nil))[:16]
+
+	for _, rendition := range model.PhotoRenditions {
+		content, err := utils.ResizeImage(picture, crop, rendition.Width, rendition.Height)
+		if err != nil {
+			return "", fmt.Errorf("error to resize image: %v", err)
+		}
+		if err = server.photos(r).UploadObject(model.PhotoKey(employeeId, version, rendition.Name), content, "image/png"); err != nil {
+			return "", err
+		}
+	}
 
-	employee, err := server.store.LoadEmployee(params["employeeId"])
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// the original is kept whole, so the picture can be cropped again
+	if err := server.photos(r).UploadObject(model.PhotoKey(employeeId, version, model.RenditionOriginal), picture, utils.ImageContentType(picture)); err != nil {
+		return "", err
 	}
 
-	signedUrl := ""
-	if employee == nil {
-		http.Error(w, "employee not found", http.StatusNotFound)
+	return model.PhotoKey(employeeId, version, model.RenditionProfile), nil
+}
+
+// deletePhoto removes the objects of photo, once no employee points to it.
+// A failure only leaves them behind, so it is logged and not returned.
+func (server *Server) deletePhoto(r *http.Request, photo *model.Photo) {
+	if photo == nil || photo.ObjectKey == "" {
 		return
 	}
 
-	if employee.Photo.ObjectKey != "" {
-		url, err := server.s3Client.GeneratePresignedURL(employee.Photo.ObjectKey)
-		if err == nil {
-			signedUrl = url
-		} else {
-			signedUrl = err.Error()
+	keys := []string{photo.ObjectKey}
+	if photo.HasRenditions() {
+		keys = []string{
+			photo.RenditionKey(model.RenditionThumbnail),
+			photo.RenditionKey(model.RenditionProfile),
+			photo.RenditionKey(model.RenditionOriginal),
+		}
+	}
+	for _, key := range keys {
+		if err := server.photos(r).DeleteObject(key); err != nil {
+			logging.FromContext(r.Context()).Warn("error to delete photo", "object_key", key, "error", err)
 		}
 	}
+}
+
+func (server *Server) listEmployees(r *http.Request) ([]*model.Employee, error) {
+	query := r.URL.Query()
+
+	if badge := query.Get("badge"); badge != "" {
+		return server.employees(r).ListEmployeesByBadge(badge)
+	}
+	if team := query.Get("team"); team != "" {
+		return server.employees(r).ListEmployeesByTeam(team)
+	}
+	if location := query.Get("location"); location != "" {
+		return server.employees(r).ListEmployeesByLocation(location)
+	}
+	if jobTitle := query.Get("job_title"); jobTitle != "" {
+		return server.employees(r).ListEmployeesByJobTitle(jobTitle)
+	}
+
+	return server.employees(r).ListEmployees()
+}
+
+func (server *Server) add(w http.ResponseWriter, r *http.Request) {
+	customFields, err := server.employees(r).ListCustomFields()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
 
 	form := model.NewForm()
-	form.EmployeeId.Data = employee.Id
-	form.FullName.Data = employee.FullName
-	form.Location.Data = employee.Location
-	form.JobTitle.Data = employee.JobTitle
-	if len(employee.Badges) > 0 {
-		form.Badges.Data = employee.Badges
+	form.SetCustomFields(customFields, nil)
+	form.SetPhotoLimits(server.photoLimits)
+
+	server.render(w, r, http.StatusOK, "edit", map[string]interface{}{
+		"form":         form,
+		"badges":       model.Badges,
+		"handle_types": model.HandleTypes,
+		"managers":     server.managers(r, ""),
+		"teams":        server.teamOptions(r),
+		"offices":      server.officeOptions(r),
+	})
+}
+
+// managers lists who can be picked as the manager of employeeId, that is
+// everyone else. The form still works, without the list, when it cannot be
+// loaded.
+func (server *Server) managers(r *http.Request, employeeId string) []*model.Employee {
+	employees, err := server.employees(r).ListEmployees()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to list managers", "error", err)
+		return nil
 	}
 
-	t, err := template.ParseFiles("./static/templates/view-edit.html", "./static/templates/main.html")
-	if err == nil {
-		err = t.Execute(w, map[string]interface{}{
-			"form":       form,
-			"badges":     model.Badges,
-			"url_save":   urlFor(r.Host, "/save"),
-			"signed_url": signedUrl,
-			"csrf_token": csrf.Token(r),
-		})
-		if err != nil {
-			fmt.Fprintf(w, "error to execute template: %+v\n", err)
+	managers := make([]*model.Employee, 0, len(employees))
+	for _, e := range employees {
+		if e.Id != employeeId {
+			managers = append(managers, e)
 		}
 	}
+	return managers
+}
+
+// teamOptions lists the teams an employee can join. Like managers, the
+// form is still shown when they cannot be loaded.
+func (server *Server) teamOptions(r *http.Request) []*model.Team {
+	teams, err := server.employees(r).ListTeams()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to list teams", "error", err)
+		return nil
+	}
+	return teams
+}
+
+// officeOptions lists the offices suggested for the location. Any other
+// location can still be typed, so the form is shown without them on error.
+func (server *Server) officeOptions(r *http.Request) []*model.Office {
+	offices, err := server.employees(r).ListOffices()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to list offices", "error", err)
+		return nil
+	}
+	return offices
+}
+
+func (server *Server) edit(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	if !validId(params["employeeId"]) {
+		http.Error(w, "employee not found", http.StatusNotFound)
+		return
+	}
+
+	employee, err := server.employees(r).LoadEmployee(params["employeeId"])
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "employee not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+	employee = server.signPhoto(r, employee)
+
+	customFields, err := server.employees(r).ListCustomFields()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	form := model.NewEmployeeForm(employee)
+	form.SetCustomFields(customFields, employee.Custom)
+	form.SetPhotoLimits(server.photoLimits)
+
+	server.render(w, r, http.StatusOK, "edit", map[string]interface{}{
+		"form":         form,
+		"badges":       model.Badges,
+		"handle_types": model.HandleTypes,
+		"managers":     server.managers(r, employee.Id),
+		"teams":        server.teamOptions(r),
+		"offices":      server.officeOptions(r),
+		"photo":        employee.Photo,
+	})
 }
 
 func (server *Server) save(w http.ResponseWriter, r *http.Request) {
-	session, _ := server.session.Get(r, server.sessionName)
+	// limitUploads has read the form already, if it was a multipart one
+	if r.MultipartForm == nil {
+		http.Error(w, "error to parse form data: the form must be sent as multipart/form-data", http.StatusBadRequest)
+		return
+	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, server.maxBytesReader)
-	err := r.ParseMultipartForm(server.maxBytesReader)
+	// values of fields that no longer exist are dropped, so the form must
+	// not be saved without the current definitions
+	customFields, err := server.employees(r).ListCustomFields()
 	if err != nil {
-		http.Error(w, fmt.Errorf("error to parse form data: %v", err).Error(), http.StatusBadRequest)
+		server.serverError(w, r, err)
 		return
 	}
 
 	form := model.NewForm()
-	err = form.ValidateOnSubmit(r.MultipartForm)
+	form.SetCustomFields(customFields, nil)
+	form.SetPhotoLimits(server.photoLimits)
+	form.ValidateOnSubmit(r.MultipartForm)
+	if err = server.validateManager(r, &form); err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+	teams, err := server.validateTeams(r, &form)
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+	err = form.Err()
 
 	if err == nil {
 
@@ -286,240 +754,1077 @@ func (server *Server) save(w http.ResponseWriter, r *http.Request) {
 		location := form.Location.Data.(string)
 		jobTitle := form.JobTitle.Data.(string)
 		badges := form.Badges.Data.([]string)
+		profile := form.Profile()
+		profile.Department = model.Departments(teams, profile.Teams)
+
+		// saving without a new picture keeps the current one, which is
+		// deleted only once the update no longer points to it: replaced by
+		// a new picture or removed on the form
+		var previous *model.Photo
+		if employeeId != "" {
+			employee, err := server.employees(r).LoadEmployee(employeeId)
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "employee not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				server.serverError(w, r, err)
+				return
+			}
+			previous = employee.Photo
+		}
 
 		if employeeId == "" {
-			employeeId, err = server.store.AddEmployee(
+			employeeId, err = server.employees(r).AddEmployee(
 				"",
 				fullName,
 				location,
 				jobTitle,
 				badges,
+				profile,
 			)
 		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			server.serverError(w, r, err)
 			return
 		}
 
 		if employeeId != "" {
 			key := ""
+			if previous != nil && !form.RemovesPhoto() {
+				key = previous.ObjectKey
+			}
 			if form.Photo.Data != nil {
-				imageBytes, err := utils.ResizeImage(form.Photo.Data.([]byte), 120, 160)
-				if err != nil {
-					http.Error(w, fmt.Errorf("error to resize image: %v", err).Error(), http.StatusInternalServerError)
-					return
-				}
-
-				// save the image to s3
-				prefix := "employee_pic/"
-				key = prefix + employeeId + ".png"
-				err = server.s3Client.UploadObject(key, imageBytes)
+				key, err = server.uploadPhoto(r, employeeId, form.Photo.Data.([]byte), form.Crop())
 				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
+					server.serverError(w, r, err)
 					return
 				}
 			}
 
-			err = server.store.UpdateEmployee(
+			err = server.employees(r).UpdateEmployee(
 				employeeId,
 				key,
 				fullName,
 				location,
 				jobTitle,
 				badges,
+				profile,
 			)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				server.serverError(w, r, err)
 				return
 			}
-		}
 
-		session.Values[server.flashTemplate] = []string{"Saved!"}
-		session.Save(r, w)
-		//flash("Saved!")
-		//return redirect(url_for("home"))
+			if previous != nil && previous.ObjectKey != key {
+				server.deletePhoto(r, previous)
+			}
+		}
 
-		http.Redirect(w, r, urlFor(r.Host, "/"), http.StatusMovedPermanently)
+		server.addFlash(w, r, flashSuccess, "Saved!")
+		server.redirect(w, r, "home")
 	} else {
-		http.Error(w, fmt.Errorf("form failed validate: %v", err).Error(), http.StatusBadRequest)
+		server.invalidForm(w, r, form)
 	}
 }
 
-func (server *Server) view(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
+// validateManager checks that the chosen manager is an existing employee
+// who does not report to the one being saved, which the form cannot tell on
+// its own, and records an error on the field otherwise. The error returned
+// is from the store.
+//
+// The check and the update are separate store calls, and DynamoDB cannot
+// run them in one transaction, so two people moving managers at the same
+// time can still close a cycle. Nothing loops on one: the org chart, the
+// recursive queries and reportingLine all stop at employees already seen,
+// and the cycle stays visible until a manager is changed again.
+func (server *Server) validateManager(r *http.Request, form *model.Form) error {
+	managerId := form.Manager.ToString()
+	if managerId == "" || !form.Manager.Valid() {
+		return nil
+	}
 
-	employee, err := server.store.LoadEmployee(params["employeeId"])
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	} else if employee == nil {
-		http.Error(w, "employee not found", http.StatusNotFound)
-		return
+	manager, err := server.employees(r).LoadEmployee(managerId)
+	if errors.Is(err, store.ErrNotFound) {
+		form.Manager.AddError("'%s' employee '%s' does not exist", form.Manager.Label, managerId)
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	if employee.Photo.ObjectKey != "" {
-		url, err := server.s3Client.GeneratePresignedURL(employee.Photo.ObjectKey)
-		if err == nil {
-			employee.Photo.SignedUrl = url
-		} else {
-			employee.Photo.SignedUrl = err.Error()
-		}
-	}
-
-	urlEdit := urlFor(r.Host, "/edit")
-	urlHome := urlFor(r.Host, "/")
-
-	templateStr := fmt.Sprintf(`
-	    {{ template "main" .}}
-	    {{ define "head" }}
-	        {{.employee.FullName}}
-	        <a class="btn btn-primary float-right" href="%s/{{.employee.Id}}">Edit</a>
-	        <a class="btn btn-primary float-right" href="%s">Home</a>
-	    {{ end }}
-	    {{ define "body" }}
-
-	  	<div class="row">
-			<div class="col-md-4">
-				{{ if .employee.Photo.SignedUrl }}
-				<img alt="Mugshot" src="{{ .employee.Photo.SignedUrl }}" />
-				{{ end }}
-			</div>
-
-	    	<div class="col-md-8">
-				<div class="form-group row">
-					<label class="col-sm-2">{{ .form.Location.Label }}</label>
-					<div class="col-sm-10">
-					{{.employee.Location}}
-					</div>
-				</div>
-	      		<div class="form-group row">
-	        		<label class="col-sm-2">{{.form.JobTitle.Label}}</label>
-					<div class="col-sm-10">
-					{{.employee.JobTitle}}
-					</div>
-	      		</div>
-				{{ $employee := .employee }}
-				{{ range $key, $badge := .badges }}
-				<div class="form-check">
-					{{ if $employee.HasBadge $key }}
-					<span class="badge badge-primary"><i class="fa fa-{{$key}}"></i> {{$badge}}</span>
-					{{ end }}
-				</div>
-				{{ end }}
-				&nbsp;
-	    	</div>
-	  	</div>
-	    {{ end }}
-		`, urlEdit, urlHome)
-
-	t, _ := template.New("view").Parse(templateStr)
-	t, err = t.ParseFiles("./static/templates/main.html")
-	if err == nil {
-		err = t.Execute(w, map[string]interface{}{
-			"form":     model.NewForm(),
-			"badges":   model.Badges,
-			"employee": employee,
-		})
-		if err != nil {
-			fmt.Fprintf(w, "error to execute template: %+v\n", err)
+	// a new employee has no one under them yet
+	employeeId := form.EmployeeId.ToString()
+	if employeeId == "" {
+		return nil
+	}
+
+	subordinates, err := server.employees(r).ListSubordinates(employeeId)
+	if err != nil {
+		return err
+	}
+	for _, s := range subordinates {
+		if s.Id == managerId {
+			form.Manager.AddError("'%s' %s already reports to this employee, which would make a cycle", form.Manager.Label, manager.FullName)
 		}
 	}
+
+	return nil
 }
 
-func (server *Server) delete(w http.ResponseWriter, r *http.Request) {
-	session, _ := server.session.Get(r, server.sessionName)
+// validateTeams records an error on the field for each chosen team that
+// does not exist, and returns the teams it checked against, none when no
+// team was chosen. The error returned is from the store.
+func (server *Server) validateTeams(r *http.Request, form *model.Form) ([]*model.Team, error) {
+	chosen, _ := form.Teams.Data.([]string)
+	if len(chosen) == 0 || !form.Teams.Valid() {
+		return nil, nil
+	}
 
-	params := mux.Vars(r)
-	err := server.store.DeleteEmployee(params["employeeId"])
+	teams, err := server.employees(r).ListTeams()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+	exist := make(map[string]bool, len(teams))
+	for _, t := range teams {
+		exist[t.Id] = true
 	}
 
-	session.Values[server.flashTemplate] = []string{"Deleted!"}
-	session.Save(r, w)
-	//flash("Deleted!")
-
-	http.Redirect(w, r, urlFor(r.Host, "/"), http.StatusMovedPermanently)
+	for _, id := range chosen {
+		if !exist[id] {
+			form.Teams.AddError("'%s' team '%s' does not exist", form.Teams.Label, id)
+		}
+	}
+	return teams, nil
 }
 
-func (server *Server) info(w http.ResponseWriter, r *http.Request) {
-	session, _ := server.session.Get(r, server.sessionName)
-
-	urlStress60 := urlFor(r.Host, "/info/stress_cpu/60")
-	urlStress300 := urlFor(r.Host, "/info/stress_cpu/300")
-	urlStress600 := urlFor(r.Host, "/info/stress_cpu/600")
-
-	templateStr := fmt.Sprintf(`
-		{{ template "main" .}}
-		{{ define "head" }}
-			Instance Info
-		{{ end }}
-		{{ define "body" }}
-		<b>instance_id</b>: {{.g.instance_id}} <br/>
-		<b>availability_zone</b>: {{.g.availablity_zone}} <br/>
-		<hr/>
-		<small>Stress cpu:
-		<a href="%s">1 min</a>,
-		<a href="%s">5 min</a>,
-		<a href="%s">10 min</a>
-		</small>
-		{{ end }}
-	`, urlStress60, urlStress300, urlStress600)
-
-	t, _ := template.New("info").Parse(templateStr)
-	t, err := t.ParseFiles("./static/templates/main.html")
-	if err == nil {
-		flashedMessages, _ := session.Values[server.flashTemplate].([]string)
-		if len(flashedMessages) > 0 {
-			session.Values[server.flashTemplate] = nil
-			session.Save(r, w)
-		}
-
-		err = t.Execute(w, map[string]interface{}{
-			"g": map[string]string{
-				"instance_id":      server.instanceId,
-				"availablity_zone": server.availabilityZone,
-			},
-			server.flashTemplate: flashedMessages,
-		})
-		if err != nil {
-			fmt.Fprintf(w, "error to execute template: %+v\n", err)
+// invalidForm shows the submitted form again with the errors next to each
+// field, keeping what the user typed.
+func (server *Server) invalidForm(w http.ResponseWriter, r *http.Request, form model.Form) {
+	var photo *model.Photo
+	if employeeId := form.EmployeeId.ToString(); employeeId != "" {
+		employee, err := server.employees(r).LoadEmployee(employeeId)
+		if err == nil {
+			photo = server.signPhoto(r, employee).Photo
 		}
 	}
-}
 
-func (server *Server) stress(w http.ResponseWriter, r *http.Request) {
-	session, _ := server.session.Get(r, server.sessionName)
+	server.render(w, r, http.StatusUnprocessableEntity, "edit", map[string]interface{}{
+		"form":               form,
+		"badges":             model.Badges,
+		"handle_types":       model.HandleTypes,
+		"managers":           server.managers(r, form.EmployeeId.ToString()),
+		"teams":              server.teamOptions(r),
+		"offices":            server.officeOptions(r),
+		"photo":              photo,
+		server.flashTemplate: []flash{{Severity: flashError, Message: "Please fix the errors below."}},
+	})
+}
 
+func (server *Server) view(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	if !validId(params["employeeId"]) {
+		http.Error(w, "employee not found", http.StatusNotFound)
+		return
+	}
+
+	employee, err := server.employees(r).LoadEmployee(params["employeeId"])
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "employee not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	employee = server.signPhoto(r, employee)
+
+	// the page lists the direct reports only, the org chart has the rest
+	reports, err := server.employees(r).ListEmployeesByManager(employee.Id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to list direct reports", "employee_id", employee.Id, "error", err)
+	}
+	model.SortEmployees(reports)
+
+	customFields, err := server.employees(r).ListCustomFields()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to list custom fields", "error", err)
+	}
+	public := []model.CustomField{}
+	for _, field := range customFields {
+		if field.Public() && employee.CustomValue(field.Key) != "" {
+			public = append(public, field)
+		}
+	}
+
+	allTeams := server.teamOptions(r)
+	setDepartments(allTeams, []*model.Employee{employee})
+	var teams []*model.Team
+	for _, team := range allTeams {
+		if employee.InTeam(team.Id) {
+			teams = append(teams, team)
+		}
+	}
+
+	reportsTo, cutShort := server.reportingLine(r, employee)
+
+	server.render(w, r, http.StatusOK, "view", map[string]interface{}{
+		"form":          model.NewForm(),
+		"teams":         teams,
+		"office":        model.FindOffice(server.officeOptions(r), employee.Location),
+		"badges":        model.Badges,
+		"handle_types":  model.HandleTypes,
+		"employee":      employee,
+		"reports_to":    reportsTo,
+		"cut_short":     cutShort,
+		"reports":       reports,
+		"custom_fields": public,
+	})
+}
+
+// maxReportingLine bounds the walk up the hierarchy, which takes one store
+// call per level.
+const maxReportingLine = 50
+
+// reportingLine returns the managers of employee, from the direct one up to
+// the top of the organization, and whether it stopped before the top,
+// either at maxReportingLine or at a reporting cycle.
+func (server *Server) reportingLine(r *http.Request, employee *model.Employee) ([]*model.Employee, bool) {
+	line := []*model.Employee{}
+	seen := map[string]bool{employee.Id: true}
+
+	for id := employee.ManagerId; id != ""; {
+		if seen[id] {
+			logging.FromContext(r.Context()).Warn("reporting cycle", "employee_id", employee.Id, "manager_id", id)
+			return line, true
+		}
+		if len(line) == maxReportingLine {
+			logging.FromContext(r.Context()).Warn("reporting line cut short", "employee_id", employee.Id, "levels", maxReportingLine)
+			return line, true
+		}
+		seen[id] = true
+		manager, err := server.employees(r).LoadEmployee(id)
+		if errors.Is(err, store.ErrNotFound) {
+			break
+		} else if err != nil {
+			logging.FromContext(r.Context()).Error("error to load manager", "employee_id", employee.Id, "manager_id", id, "error", err)
+			break
+		}
+		line = append(line, manager)
+		id = manager.ManagerId
+	}
+
+	return line, false
+}
+
+// org shows the whole organization chart, or only the part under the
+// employee given by ?root=.
+func (server *Server) org(w http.ResponseWriter, r *http.Request) {
+	var chart []*model.OrgNode
+
+	if rootId := r.URL.Query().Get("root"); rootId != "" {
+		if !validId(rootId) {
+			http.Error(w, "employee not found", http.StatusNotFound)
+			return
+		}
+
+		root, err := server.employees(r).LoadEmployee(rootId)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "employee not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			server.serverError(w, r, err)
+			return
+		}
+
+		subordinates, err := server.employees(r).ListSubordinates(root.Id)
+		if err != nil {
+			server.serverError(w, r, err)
+			return
+		}
+		setDepartments(server.teamOptions(r), append(subordinates, root))
+		chart = []*model.OrgNode{model.OrgTree(root, subordinates)}
+	} else {
+		employees, err := server.employees(r).ListEmployees()
+		if err != nil {
+			server.serverError(w, r, err)
+			return
+		}
+		setDepartments(server.teamOptions(r), employees)
+		chart = model.OrgChart(employees)
+	}
+
+	server.render(w, r, http.StatusOK, "org", map[string]interface{}{
+		"chart": chart,
+		"root":  r.URL.Query().Get("root"),
+	})
+}
+
+// orgEntry is the JSON form of an org chart node.
+type orgEntry struct {
+	Id         string     `json:"id"`
+	FullName   string     `json:"full_name"`
+	JobTitle   string     `json:"job_title"`
+	Department string     `json:"department,omitempty"`
+	ManagerId  string     `json:"manager_id,omitempty"`
+	Reports    []orgEntry `json:"reports"`
+}
+
+func newOrgEntry(node *model.OrgNode) orgEntry {
+	entry := orgEntry{
+		Id:         node.Employee.Id,
+		FullName:   node.Employee.FullName,
+		JobTitle:   node.Employee.JobTitle,
+		Department: node.Employee.Department,
+		ManagerId:  node.Employee.ManagerId,
+		Reports:    make([]orgEntry, 0, len(node.Reports)),
+	}
+	for _, r := range node.Reports {
+		entry.Reports = append(entry.Reports, newOrgEntry(r))
+	}
+	return entry
+}
+
+// subtree returns, as JSON, the employee and everyone under them nested by
+// reporting line.
+func (server *Server) subtree(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	if !validId(params["employeeId"]) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"error": "employee not found"})
+		return
+	}
+
+	employee, err := server.employees(r).LoadEmployee(params["employeeId"])
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"error": "employee not found"})
+		return
+	} else if err != nil {
+		logging.FromContext(r.Context()).Error("error to load employee", "employee_id", params["employeeId"], "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": "error to load employee"})
+		return
+	}
+
+	subordinates, err := server.employees(r).ListSubordinates(employee.Id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to list subordinates", "employee_id", employee.Id, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": "error to list subordinates"})
+		return
+	}
+
+	setDepartments(server.teamOptions(r), append(subordinates, employee))
+	writeJSON(w, http.StatusOK, newOrgEntry(model.OrgTree(employee, subordinates)))
+}
+
+// setDepartments shows the current departments of employees, which may
+// have been renamed or left since each employee was saved.
+func setDepartments(teams []*model.Team, employees []*model.Employee) {
+	for _, e := range employees {
+		if len(e.Teams) > 0 {
+			e.Department = model.Departments(teams, e.Teams)
+		}
+	}
+}
+
+func (server *Server) delete(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if !validId(params["employeeId"]) {
+		http.Error(w, "employee not found", http.StatusNotFound)
+		return
+	}
+
+	// the photo is deleted after the employee, so it is never left pointing
+	// to missing objects
+	var photo *model.Photo
+	if employee, err := server.employees(r).LoadEmployee(params["employeeId"]); err == nil {
+		photo = employee.Photo
+	}
+
+	err := server.employees(r).DeleteEmployee(params["employeeId"])
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to delete employee", "employee_id", params["employeeId"], "error", err)
+		server.addFlash(w, r, flashError, "The employee could not be deleted, please try again.")
+	} else {
+		server.deletePhoto(r, photo)
+		server.addFlash(w, r, flashSuccess, "Deleted!")
+	}
+
+	server.redirect(w, r, "home")
+}
+
+// teams lists the departments and teams.
+func (server *Server) teams(w http.ResponseWriter, r *http.Request) {
+	teams, err := server.employees(r).ListTeams()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	server.render(w, r, http.StatusOK, "teams", map[string]interface{}{
+		"teams": teams,
+	})
+}
+
+// team shows a team with its lead and members.
+func (server *Server) team(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	if !validId(params["teamId"]) {
+		http.Error(w, "team not found", http.StatusNotFound)
+		return
+	}
+
+	team, err := server.employees(r).LoadTeam(params["teamId"])
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "team not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	members, err := server.employees(r).ListEmployeesByTeam(team.Id)
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+	model.SortEmployees(members)
+	members = server.signPhotos(r, members)
+
+	// the lead may have left without the team being updated
+	var lead *model.Employee
+	if team.LeadId != "" {
+		lead, err = server.employees(r).LoadEmployee(team.LeadId)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(r.Context()).Error("error to load team lead", "team_id", team.Id, "lead_id", team.LeadId, "error", err)
+		}
+	}
+
+	server.render(w, r, http.StatusOK, "team", map[string]interface{}{
+		"team":    team,
+		"lead":    lead,
+		"members": members,
+		"badges":  model.Badges,
+	})
+}
+
+func (server *Server) addTeam(w http.ResponseWriter, r *http.Request) {
+	server.render(w, r, http.StatusOK, "edit_team", map[string]interface{}{
+		"form":  model.NewTeamForm(),
+		"kinds": model.TeamKinds,
+		"leads": server.managers(r, ""),
+	})
+}
+
+func (server *Server) editTeam(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	if !validId(params["teamId"]) {
+		http.Error(w, "team not found", http.StatusNotFound)
+		return
+	}
+
+	team, err := server.employees(r).LoadTeam(params["teamId"])
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "team not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	server.render(w, r, http.StatusOK, "edit_team", map[string]interface{}{
+		"form":  model.NewTeamEditForm(team),
+		"kinds": model.TeamKinds,
+		"leads": server.managers(r, ""),
+	})
+}
+
+func (server *Server) saveTeam(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Errorf("error to parse form data: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	form := model.NewTeamForm()
+	form.ValidateOnSubmit(r.PostForm)
+	if leadId := form.Lead.ToString(); leadId != "" && form.Lead.Valid() {
+		_, err := server.employees(r).LoadEmployee(leadId)
+		if errors.Is(err, store.ErrNotFound) {
+			form.Lead.AddError("'%s' employee '%s' does not exist", form.Lead.Label, leadId)
+		} else if err != nil {
+			server.serverError(w, r, err)
+			return
+		}
+	}
+
+	if err := form.Err(); err != nil {
+		server.render(w, r, http.StatusUnprocessableEntity, "edit_team", map[string]interface{}{
+			"form":               form,
+			"kinds":              model.TeamKinds,
+			"leads":              server.managers(r, ""),
+			server.flashTemplate: []flash{{Severity: flashError, Message: "Please fix the errors below."}},
+		})
+		return
+	}
+
+	team := form.Team()
+	var err error
+	if team.Id == "" {
+		team.Id, err = server.employees(r).AddTeam(team)
+	} else {
+		err = server.employees(r).UpdateTeam(team)
+	}
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	server.addFlash(w, r, flashSuccess, "Saved!")
+	server.redirect(w, r, "team", "teamId", team.Id)
+}
+
+func (server *Server) deleteTeam(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if !validId(params["teamId"]) {
+		http.Error(w, "team not found", http.StatusNotFound)
+		return
+	}
+
+	err := server.employees(r).DeleteTeam(params["teamId"])
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to delete team", "team_id", params["teamId"], "error", err)
+		server.addFlash(w, r, flashError, "The team could not be deleted, please try again.")
+	} else {
+		server.addFlash(w, r, flashSuccess, "Deleted!")
+	}
+
+	server.redirect(w, r, "teams")
+}
+
+// offices lists the offices with how many employees work from each.
+func (server *Server) offices(w http.ResponseWriter, r *http.Request) {
+	offices, err := server.employees(r).ListOffices()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	employees, err := server.employees(r).ListEmployees()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+	staff := map[string]int{}
+	for _, e := range employees {
+		staff[e.Location]++
+	}
+
+	server.render(w, r, http.StatusOK, "offices", map[string]interface{}{
+		"offices": offices,
+		"staff":   staff,
+	})
+}
+
+// office shows an office with the employees located there.
+func (server *Server) office(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	if !validId(params["officeId"]) {
+		http.Error(w, "office not found", http.StatusNotFound)
+		return
+	}
+
+	office, err := server.employees(r).LoadOffice(params["officeId"])
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "office not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	staff, err := server.employees(r).ListEmployeesByLocation(office.Name)
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+	model.SortEmployees(staff)
+	staff = server.signPhotos(r, staff)
+
+	server.render(w, r, http.StatusOK, "office", map[string]interface{}{
+		"office": office,
+		"staff":  staff,
+		"badges": model.Badges,
+	})
+}
+
+// multipartMemory is how much of a multipart form is kept in memory; the
+// rest, most of a picture, goes to a temporary file.
+const multipartMemory = 256 << 10
+
+// limitUploads reads multipart forms, the ones with a picture, within the
+// photo size limit. The CSRF check reads the form for its token, so the
+// limit must be in place before it, or the body would be read unbounded.
+// As the form is read before the token is checked, it is kept mostly on
+// disk, and removed once the request is served.
+func (server *Server) limitUploads(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+			r.Body = http.MaxBytesReader(w, r.Body, server.maxBytesReader)
+			err := r.ParseMultipartForm(multipartMemory)
+			if r.MultipartForm != nil {
+				defer r.MultipartForm.RemoveAll()
+			}
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				// the form cannot be shown again without the rest of its data
+				http.Error(w, fmt.Sprintf("The picture must be at most %s.", model.FormatBytes(server.photoLimits.MaxBytes)), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, fmt.Errorf("error to parse form data: %v", err).Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminOnly asks for the ADMIN_USER and ADMIN_PASSWORD credentials. The
+// administration pages do not exist while no password is configured.
+func (server *Server) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if server.config.AdminPassword == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(server.config.AdminUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(server.config.AdminPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Employee Directory administration", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// customFields lists the custom fields next to a form for adding one, or
+// for changing the one in the url.
+func (server *Server) customFields(w http.ResponseWriter, r *http.Request) {
+	fields, err := server.employees(r).ListCustomFields()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	field := model.CustomField{Type: model.CustomText, Visibility: model.VisibilityPublic, Position: len(fields) + 1}
+	key, editing := mux.Vars(r)["key"]
+	if editing {
+		found := false
+		for _, f := range fields {
+			if f.Key == key {
+				field, found = f, true
+			}
+		}
+		if !found {
+			http.Error(w, "custom field not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	server.render(w, r, http.StatusOK, "custom_fields", map[string]interface{}{
+		"fields":  fields,
+		"field":   field,
+		"editing": editing,
+		"types":   model.CustomFieldTypes,
+	})
+}
+
+func (server *Server) saveCustomField(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Errorf("error to parse form data: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	field := model.CustomField{
+		Key:        strings.TrimSpace(r.PostForm.Get("key")),
+		Label:      r.PostForm.Get("label"),
+		Type:       r.PostForm.Get("type"),
+		Options:    strings.Split(r.PostForm.Get("options"), "\n"),
+		Required:   r.PostForm.Get("required") == "on",
+		Visibility: r.PostForm.Get("visibility"),
+	}
+	field.Normalize()
+	if !field.HasOptions() {
+		field.Options = nil
+	}
+	editing := r.PostForm.Get("editing") == "true"
+
+	position, err := strconv.Atoi(strings.TrimSpace(r.PostForm.Get("position")))
+	field.Position = position
+	if err == nil {
+		err = field.Validate()
+	} else {
+		err = fmt.Errorf("position must be a whole number")
+	}
+
+	fields, listErr := server.employees(r).ListCustomFields()
+	if listErr != nil {
+		server.serverError(w, r, listErr)
+		return
+	}
+	// saving replaces the field with the same key, which only editing may do
+	if err == nil && !editing {
+		for _, f := range fields {
+			if f.Key == field.Key {
+				err = fmt.Errorf("custom field '%s' already exists", field.Key)
+			}
+		}
+	}
+
+	if err == nil {
+		err = server.employees(r).SaveCustomField(field)
+		if err != nil {
+			server.serverError(w, r, err)
+			return
+		}
+
+		server.addFlash(w, r, flashSuccess, fmt.Sprintf("Custom field '%s' saved!", field.Label))
+		server.redirect(w, r, "custom_fields")
+		return
+	}
+
+	server.render(w, r, http.StatusUnprocessableEntity, "custom_fields", map[string]interface{}{
+		"fields":             fields,
+		"field":              field,
+		"editing":            editing,
+		"types":              model.CustomFieldTypes,
+		server.flashTemplate: []flash{{Severity: flashError, Message: err.Error()}},
+	})
+}
+
+func (server *Server) deleteCustomField(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	err := server.employees(r).DeleteCustomField(key)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to delete custom field", "key", key, "error", err)
+		server.addFlash(w, r, flashError, "The custom field could not be deleted, please try again.")
+	} else {
+		server.addFlash(w, r, flashSuccess, fmt.Sprintf("Custom field '%s' deleted!", key))
+	}
+
+	server.redirect(w, r, "custom_fields")
+}
+
+// adminOffices lists the offices next to a form for adding one, or for
+// changing the one in the url.
+func (server *Server) adminOffices(w http.ResponseWriter, r *http.Request) {
+	offices, err := server.employees(r).ListOffices()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	form := model.NewOfficeForm()
+	officeId, editing := mux.Vars(r)["officeId"]
+	if editing {
+		var office *model.Office
+		for _, o := range offices {
+			if o.Id == officeId {
+				office = o
+			}
+		}
+		if office == nil {
+			http.Error(w, "office not found", http.StatusNotFound)
+			return
+		}
+		form = model.NewOfficeEditForm(office)
+	}
+
+	server.render(w, r, http.StatusOK, "admin_offices", map[string]interface{}{
+		"offices": offices,
+		"form":    form,
+	})
+}
+
+// saveOffice adds or changes an office. Renaming an office moves the
+// employees located there along with it.
+func (server *Server) saveOffice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Errorf("error to parse form data: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	offices, err := server.employees(r).ListOffices()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	form := model.NewOfficeForm()
+	form.ValidateOnSubmit(r.PostForm)
+	office := form.Office()
+
+	var previous *model.Office
+	for _, o := range offices {
+		if o.Id == office.Id {
+			previous = o
+		} else if strings.EqualFold(o.Name, office.Name) {
+			form.Name.AddError("'%s' another office is called '%s'", form.Name.Label, o.Name)
+		}
+	}
+	if office.Id != "" && previous == nil {
+		form.OfficeId.AddError("'%s' office '%s' does not exist", form.OfficeId.Label, office.Id)
+	}
+
+	if err := form.Err(); err != nil {
+		server.render(w, r, http.StatusUnprocessableEntity, "admin_offices", map[string]interface{}{
+			"offices":            offices,
+			"form":               form,
+			server.flashTemplate: []flash{{Severity: flashError, Message: "Please fix the errors below."}},
+		})
+		return
+	}
+
+	if office.Id == "" {
+		office.Id, err = server.employees(r).AddOffice(office)
+	} else {
+		err = server.employees(r).UpdateOffice(office)
+		if err == nil && previous.Name != office.Name {
+			err = server.employees(r).RenameLocation(previous.Name, office.Name)
+		}
+	}
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	server.addFlash(w, r, flashSuccess, fmt.Sprintf("Office '%s' saved!", office.Name))
+	server.redirect(w, r, "admin_offices")
+}
+
+// deleteOffice removes an office. Its employees keep the location, as free
+// text.
+func (server *Server) deleteOffice(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if !validId(params["officeId"]) {
+		http.Error(w, "office not found", http.StatusNotFound)
+		return
+	}
+
+	err := server.employees(r).DeleteOffice(params["officeId"])
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error to delete office", "office_id", params["officeId"], "error", err)
+		server.addFlash(w, r, flashError, "The office could not be deleted, please try again.")
+	} else {
+		server.addFlash(w, r, flashSuccess, "Deleted!")
+	}
+
+	server.redirect(w, r, "admin_offices")
+}
+
+// locations groups the locations typed on the employees by likely place,
+// so an administrator can merge the spellings of each into one. Groups that
+// already match an office exactly are left out.
+func (server *Server) locations(w http.ResponseWriter, r *http.Request) {
+	employees, err := server.employees(r).ListEmployees()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	offices, err := server.employees(r).ListOffices()
+	if err != nil {
+		server.serverError(w, r, err)
+		return
+	}
+
+	clusters := []model.LocationCluster{}
+	for _, c := range model.ClusterLocations(employees, offices) {
+		if !c.Clean() {
+			clusters = append(clusters, c)
+		}
+	}
+
+	server.render(w, r, http.StatusOK, "locations", map[string]interface{}{
+		"clusters": clusters,
+		"offices":  offices,
+	})
+}
+
+// mergeLocations renames every checked location to the target one.
+func (server *Server) mergeLocations(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Errorf("error to parse form data: %v", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the target is typed by the administrator, so it must pass the rules
+	// of the location field
+	to := model.Normalize(r.PostForm.Get("to"))
+	err := fmt.Errorf("choose the location to merge into")
+	if to != "" {
+		if err = model.MaxLength(model.MaxTextLength)(to); err == nil {
+			err = model.Printable()(to)
+		}
+	}
+	if err != nil {
+		server.addFlash(w, r, flashError, fmt.Sprintf("The locations could not be merged: %v.", err))
+		server.redirect(w, r, "locations")
+		return
+	}
+
+	merged := 0
+	for _, from := range r.PostForm["from"] {
+		if from == to {
+			continue
+		}
+		if err := server.employees(r).RenameLocation(from, to); err != nil {
+			logging.FromContext(r.Context()).Error("error to rename location", "from", from, "to", to, "error", err)
+			server.addFlash(w, r, flashError, fmt.Sprintf("'%s' could not be merged, please try again.", from))
+			server.redirect(w, r, "locations")
+			return
+		}
+		merged++
+	}
+
+	server.addFlash(w, r, flashSuccess, fmt.Sprintf("%d location(s) merged into '%s'!", merged, to))
+	server.redirect(w, r, "locations")
+}
+
+func (server *Server) info(w http.ResponseWriter, r *http.Request) {
+	server.render(w, r, http.StatusOK, "info", map[string]interface{}{
+		"g": map[string]string{
+			"instance_id":      server.instanceId,
+			"availablity_zone": server.availabilityZone,
+		},
+	})
+}
+
+func (server *Server) stress(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	if params["seconds"] == "60" || params["seconds"] == "300" || params["seconds"] == "600" {
 		err := exec.Command("stress", "--cpu", "8", "--timeout", params["seconds"]).Start()
 		if err != nil {
-			msgErr := fmt.Errorf("error to simulate cpu stress with param '%s'. Details: '%s'", params["seconds"], err).Error()
-			http.Error(w, msgErr, http.StatusInternalServerError)
-			return
+			logging.FromContext(r.Context()).Error("error to simulate cpu stress", "seconds", params["seconds"], "error", err)
+			server.addFlash(w, r, flashError, "Could not stress the CPU")
+		} else {
+			server.addFlash(w, r, flashSuccess, "Stressing CPU")
 		}
+	} else {
+		server.addFlash(w, r, flashError, fmt.Sprintf("'%s' is not a supported duration", params["seconds"]))
+	}
+
+	server.redirect(w, r, "info")
+}
+
+// photo serves a rendition of an employee photo from the photo store, and
+// nothing else the bucket or directory may hold. Versioned keys never change
+// content, so browsers keep them for a year and a matching If-None-Match is
+// answered without reading the store.
+func (server *Server) photo(w http.ResponseWriter, r *http.Request) {
+	objectKey := mux.Vars(r)["objectKey"]
+
+	if !model.IsPhotoKey(objectKey) {
+		http.Error(w, "photo not found", http.StatusNotFound)
+		return
+	}
 
-		session.Values[server.flashTemplate] = []string{"Stressing CPU"}
-		session.Save(r, w)
-		//flash("Stressing CPU")
+	etag, cacheControl := `"`+model.PhotoVersion(objectKey)+"/"+path.Base(objectKey)+`"`, "private, max-age=31536000, immutable"
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-		http.Redirect(w, r, urlFor(r.Host, "/info"), http.StatusMovedPermanently)
+	content, err := server.photos(r).ReadObject(objectKey)
+	if err != nil {
+		http.Error(w, "photo not found", http.StatusNotFound)
+		return
 	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	// objects stored before uploads were checked may hold anything, and
+	// those are never shown in the page of the application
+	if contentType := utils.ImageContentType(content); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": path.Base(objectKey)}))
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(objectKey)}))
+	}
+	// ServeContent answers If-None-Match and range requests
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+// avatar draws the initials given in the url on the color of the employee,
+// at the size of a photo rendition. The picture depends on the url alone,
+// so it is cached like the versioned photos, and the store is not read.
+func (server *Server) avatar(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	rendition, exist := model.FindRendition(params["rendition"])
+	if !exist {
+		http.Error(w, "avatar not found", http.StatusNotFound)
+		return
+	}
+
+	// the initials come from the url, so anything else is left out of
+	// the picture
+	var initials []rune
+	for _, c := range r.URL.Query().Get("initials") {
+		if unicode.IsLetter(c) && len(initials) < 2 {
+			initials = append(initials, unicode.ToUpper(c))
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Write(utils.AvatarSVG(string(initials), model.AvatarColor(params["employeeId"]), rendition.Width, rendition.Height))
 }
 
 func (server *Server) monitor(w http.ResponseWriter, r *http.Request) {
 	healthStatus := map[bool]string{true: "OK", false: "PROBLEM"}
 
-	isDbHealthy := server.store.IsHealthy()
-	isS3Healthy := server.s3Client.IsHealthy()
+	db, _ := server.health.Status("database")
+	photos, _ := server.health.Status("photos")
 
-	msg := fmt.Sprintf("s3 status: %s\ndatabase status: %s\n", healthStatus[isDbHealthy], healthStatus[isS3Healthy])
+	msg := fmt.Sprintf("s3 status: %s\ndatabase status: %s\n", healthStatus[photos.Healthy], healthStatus[db.Healthy])
 
-	if isDbHealthy && isS3Healthy {
+	if db.Healthy && photos.Healthy {
 		w.Write([]byte(msg))
 	} else {
 		http.Error(w, msg, http.StatusServiceUnavailable)
 	}
 }
+
+func (server *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+func (server *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	status, code := "ok", http.StatusOK
+	if !server.health.Ready() {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, map[string]interface{}{
+		"status":       status,
+		"dependencies": server.health.Statuses(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
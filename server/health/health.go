@@ -0,0 +1,161 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency answers. It must give up once ctx is
+// done, which happens when the check times out or the checker stops.
+type Check func(ctx context.Context) error
+
+type Status struct {
+	Name        string     `json:"name"`
+	Healthy     bool       `json:"healthy"`
+	LatencyMs   float64    `json:"latency_ms"`
+	CheckedAt   *time.Time `json:"checked_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Checker runs the dependency checks in the background and keeps the latest
+// result of each one, so probes are answered from memory instead of calling
+// the dependencies on every hit.
+type Checker struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	statuses map[string]*Status
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+func NewChecker(interval, timeout time.Duration) *Checker {
+	return &Checker{
+		interval: interval,
+		timeout:  timeout,
+		checks:   map[string]Check{},
+		statuses: map[string]*Status{},
+		stop:     make(chan struct{}),
+	}
+}
+
+// Add registers a check. It must be called before Start.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.names = append(c.names, name)
+	c.checks[name] = check
+	c.statuses[name] = &Status{Name: name, LastError: "not checked yet"}
+}
+
+func (c *Checker) Start() {
+	for _, name := range c.names {
+		c.done.Add(1)
+		go c.run(name, c.checks[name])
+	}
+}
+
+func (c *Checker) Stop() {
+	close(c.stop)
+	c.done.Wait()
+}
+
+func (c *Checker) run(name string, check Check) {
+	defer c.done.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.runOnce(name, check)
+
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) runOnce(name string, check Check) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	err := check(ctx)
+	now := time.Now()
+
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("check timed out after %s", c.timeout)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := c.statuses[name]
+	status.Healthy = err == nil
+	status.LatencyMs = float64(now.Sub(start).Microseconds()) / 1000
+	status.CheckedAt = &now
+	if err != nil {
+		status.LastError = err.Error()
+		status.LastErrorAt = &now
+	} else if status.LastErrorAt == nil {
+		status.LastError = ""
+	}
+}
+
+// Statuses returns a copy of the latest results in registration order.
+func (c *Checker) Statuses() []Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(c.names))
+	for _, name := range c.names {
+		statuses = append(statuses, *c.statuses[name])
+	}
+
+	return statuses
+}
+
+func (c *Checker) Status(name string) (Status, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status, exist := c.statuses[name]
+	if !exist {
+		return Status{}, false
+	}
+
+	return *status, true
+}
+
+func (c *Checker) Ready() bool {
+	for _, status := range c.Statuses() {
+		if !status.Healthy {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+	"github.com/moura1001/aws-employee-directory-application/server/store"
+	"github.com/moura1001/aws-employee-directory-application/server/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "employee_directory"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	storeDuration *prometheus.HistogramVec
+	storeErrors   *prometheus.CounterVec
+
+	photoUploadBytes *prometheus.HistogramVec
+	photoPresigns    *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by mux route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by mux route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Latency of store operations by backend and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"backend", "operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_operation_errors_total",
+			Help:      "Failed store operations by backend and method.",
+		}, []string{"backend", "operation"}),
+		photoUploadBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "photo_upload_bytes",
+			Help:      "Size of uploaded photo objects.",
+			Buckets:   prometheus.ExponentialBuckets(4<<10, 2, 10),
+		}, []string{"backend"}),
+		photoPresigns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "photo_presign_total",
+			Help:      "Photo URLs generated by backend and result.",
+		}, []string{"backend", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.storeDuration,
+		m.storeErrors,
+		m.photoUploadBytes,
+		m.photoPresigns,
+	)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records requests under their mux path template, so that
+// /employee/1 and /employee/2 share the /employee/{employeeId} series.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		start := time.Now()
+		rec := utils.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		m.requests.WithLabelValues(route, r.Method, strconv.Itoa(rec.Status)).Inc()
+		m.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *Metrics) observeStore(backend, operation string, start time.Time, err error) {
+	m.storeDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+	// a missing record is an answer, not a failure of the store
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		m.storeErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
+
+// RegisterDirectoryGauges exposes employee and badge totals. Listing every
+// employee can be expensive, so the totals are recomputed at most once per
+// refresh interval regardless of the scrape frequency.
+func (m *Metrics) RegisterDirectoryGauges(s store.EmployeeStore, refresh time.Duration) {
+	m.registry.MustRegister(&directoryCollector{
+		store:   s,
+		refresh: refresh,
+		employees: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "employees"),
+			"Employees in the directory.", nil, nil),
+		badges: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "badge_holders"),
+			"Employees holding each badge.", []string{"badge"}, nil),
+	})
+}
+
+type directoryCollector struct {
+	store   store.EmployeeStore
+	refresh time.Duration
+
+	employees *prometheus.Desc
+	badges    *prometheus.Desc
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+	total       int
+	badgeTotals map[string]int
+}
+
+func (c *directoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.employees
+	ch <- c.badges
+}
+
+func (c *directoryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.badgeTotals == nil || time.Since(c.lastRefresh) >= c.refresh {
+		employees, err := c.store.ListEmployees()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.employees, err)
+			return
+		}
+
+		c.total = len(employees)
+		c.badgeTotals = map[string]int{}
+		for key := range model.Badges {
+			c.badgeTotals[key] = 0
+		}
+		for _, e := range employees {
+			for _, b := range e.Badges {
+				if _, exist := model.Badges[b]; exist {
+					c.badgeTotals[b]++
+				}
+			}
+		}
+		c.lastRefresh = time.Now()
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.employees, prometheus.GaugeValue, float64(c.total))
+	for badge, total := range c.badgeTotals {
+		ch <- prometheus.MustNewConstMetric(c.badges, prometheus.GaugeValue, float64(total), badge)
+	}
+}
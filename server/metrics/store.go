@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"io"
+	"time"
+
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+	"github.com/moura1001/aws-employee-directory-application/server/store"
+)
+
+type employeeStore struct {
+	store.EmployeeStore
+	metrics *Metrics
+	backend string
+}
+
+// EmployeeStore wraps s so that every call is timed and counted under the
+// given backend label.
+func (m *Metrics) EmployeeStore(backend string, s store.EmployeeStore) store.EmployeeStore {
+	return &employeeStore{EmployeeStore: s, metrics: m, backend: backend}
+}
+
+func (s *employeeStore) ListEmployees() ([]*model.Employee, error) {
+	start := time.Now()
+	emps, err := s.EmployeeStore.ListEmployees()
+	s.metrics.observeStore(s.backend, "ListEmployees", start, err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByLocation(location string) ([]*model.Employee, error) {
+	start := time.Now()
+	emps, err := s.EmployeeStore.ListEmployeesByLocation(location)
+	s.metrics.observeStore(s.backend, "ListEmployeesByLocation", start, err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error) {
+	start := time.Now()
+	emps, err := s.EmployeeStore.ListEmployeesByJobTitle(jobTitle)
+	s.metrics.observeStore(s.backend, "ListEmployeesByJobTitle", start, err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByBadge(badge string) ([]*model.Employee, error) {
+	start := time.Now()
+	emps, err := s.EmployeeStore.ListEmployeesByBadge(badge)
+	s.metrics.observeStore(s.backend, "ListEmployeesByBadge", start, err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByTeam(teamId string) ([]*model.Employee, error) {
+	start := time.Now()
+	emps, err := s.EmployeeStore.ListEmployeesByTeam(teamId)
+	s.metrics.observeStore(s.backend, "ListEmployeesByTeam", start, err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByManager(managerId string) ([]*model.Employee, error) {
+	start := time.Now()
+	emps, err := s.EmployeeStore.ListEmployeesByManager(managerId)
+	s.metrics.observeStore(s.backend, "ListEmployeesByManager", start, err)
+	return emps, err
+}
+
+func (s *employeeStore) LoadEmployee(employeeId string) (*model.Employee, error) {
+	start := time.Now()
+	emp, err := s.EmployeeStore.LoadEmployee(employeeId)
+	s.metrics.observeStore(s.backend, "LoadEmployee", start, err)
+	return emp, err
+}
+
+func (s *employeeStore) ListSubordinates(employeeId string) ([]*model.Employee, error) {
+	start := time.Now()
+	emps, err := s.EmployeeStore.ListSubordinates(employeeId)
+	s.metrics.observeStore(s.backend, "ListSubordinates", start, err)
+	return emps, err
+}
+
+func (s *employeeStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error) {
+	start := time.Now()
+	id, err := s.EmployeeStore.AddEmployee(objectKey, fullName, location, jobTitle, badges, profile)
+	s.metrics.observeStore(s.backend, "AddEmployee", start, err)
+	return id, err
+}
+
+func (s *employeeStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error {
+	start := time.Now()
+	err := s.EmployeeStore.UpdateEmployee(employeeId, objectKey, fullName, location, jobTitle, badges, profile)
+	s.metrics.observeStore(s.backend, "UpdateEmployee", start, err)
+	return err
+}
+
+func (s *employeeStore) DeleteEmployee(employeeId string) error {
+	start := time.Now()
+	err := s.EmployeeStore.DeleteEmployee(employeeId)
+	s.metrics.observeStore(s.backend, "DeleteEmployee", start, err)
+	return err
+}
+
+func (s *employeeStore) ListCustomFields() ([]model.CustomField, error) {
+	start := time.Now()
+	fields, err := s.EmployeeStore.ListCustomFields()
+	s.metrics.observeStore(s.backend, "ListCustomFields", start, err)
+	return fields, err
+}
+
+func (s *employeeStore) SaveCustomField(field model.CustomField) error {
+	start := time.Now()
+	err := s.EmployeeStore.SaveCustomField(field)
+	s.metrics.observeStore(s.backend, "SaveCustomField", start, err)
+	return err
+}
+
+func (s *employeeStore) DeleteCustomField(key string) error {
+	start := time.Now()
+	err := s.EmployeeStore.DeleteCustomField(key)
+	s.metrics.observeStore(s.backend, "DeleteCustomField", start, err)
+	return err
+}
+
+func (s *employeeStore) ListTeams() ([]*model.Team, error) {
+	start := time.Now()
+	teams, err := s.EmployeeStore.ListTeams()
+	s.metrics.observeStore(s.backend, "ListTeams", start, err)
+	return teams, err
+}
+
+func (s *employeeStore) LoadTeam(teamId string) (*model.Team, error) {
+	start := time.Now()
+	team, err := s.EmployeeStore.LoadTeam(teamId)
+	s.metrics.observeStore(s.backend, "LoadTeam", start, err)
+	return team, err
+}
+
+func (s *employeeStore) AddTeam(team model.Team) (string, error) {
+	start := time.Now()
+	id, err := s.EmployeeStore.AddTeam(team)
+	s.metrics.observeStore(s.backend, "AddTeam", start, err)
+	return id, err
+}
+
+func (s *employeeStore) UpdateTeam(team model.Team) error {
+	start := time.Now()
+	err := s.EmployeeStore.UpdateTeam(team)
+	s.metrics.observeStore(s.backend, "UpdateTeam", start, err)
+	return err
+}
+
+func (s *employeeStore) DeleteTeam(teamId string) error {
+	start := time.Now()
+	err := s.EmployeeStore.DeleteTeam(teamId)
+	s.metrics.observeStore(s.backend, "DeleteTeam", start, err)
+	return err
+}
+
+func (s *employeeStore) ListOffices() ([]*model.Office, error) {
+	start := time.Now()
+	offices, err := s.EmployeeStore.ListOffices()
+	s.metrics.observeStore(s.backend, "ListOffices", start, err)
+	return offices, err
+}
+
+func (s *employeeStore) LoadOffice(officeId string) (*model.Office, error) {
+	start := time.Now()
+	office, err := s.EmployeeStore.LoadOffice(officeId)
+	s.metrics.observeStore(s.backend, "LoadOffice", start, err)
+	return office, err
+}
+
+func (s *employeeStore) AddOffice(office model.Office) (string, error) {
+	start := time.Now()
+	id, err := s.EmployeeStore.AddOffice(office)
+	s.metrics.observeStore(s.backend, "AddOffice", start, err)
+	return id, err
+}
+
+func (s *employeeStore) UpdateOffice(office model.Office) error {
+	start := time.Now()
+	err := s.EmployeeStore.UpdateOffice(office)
+	s.metrics.observeStore(s.backend, "UpdateOffice", start, err)
+	return err
+}
+
+func (s *employeeStore) DeleteOffice(officeId string) error {
+	start := time.Now()
+	err := s.EmployeeStore.DeleteOffice(officeId)
+	s.metrics.observeStore(s.backend, "DeleteOffice", start, err)
+	return err
+}
+
+func (s *employeeStore) RenameLocation(from, to string) error {
+	start := time.Now()
+	err := s.EmployeeStore.RenameLocation(from, to)
+	s.metrics.observeStore(s.backend, "RenameLocation", start, err)
+	return err
+}
+
+func (s *employeeStore) Close() error {
+	if closer, ok := s.EmployeeStore.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type photoStore struct {
+	store.PhotoStore
+	metrics *Metrics
+	backend string
+}
+
+// PhotoStore wraps s so that uploads, reads and presigned URLs are measured
+// under the given backend label.
+func (m *Metrics) PhotoStore(backend string, s store.PhotoStore) store.PhotoStore {
+	return &photoStore{PhotoStore: s, metrics: m, backend: backend}
+}
+
+func (s *photoStore) GeneratePresignedURL(objectKey string, expiry time.Duration) (string, time.Time, error) {
+	start := time.Now()
+	url, expires, err := s.PhotoStore.GeneratePresignedURL(objectKey, expiry)
+	s.metrics.observeStore(s.backend, "GeneratePresignedURL", start, err)
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	s.metrics.photoPresigns.WithLabelValues(s.backend, result).Inc()
+
+	return url, expires, err
+}
+
+func (s *photoStore) UploadObject(objectKey string, content []byte, contentType string) error {
+	start := time.Now()
+	err := s.PhotoStore.UploadObject(objectKey, content, contentType)
+	s.metrics.observeStore(s.backend, "UploadObject", start, err)
+	if err == nil {
+		s.metrics.photoUploadBytes.WithLabelValues(s.backend).Observe(float64(len(content)))
+	}
+	return err
+}
+
+func (s *photoStore) ReadObject(objectKey string) ([]byte, error) {
+	start := time.Now()
+	content, err := s.PhotoStore.ReadObject(objectKey)
+	s.metrics.observeStore(s.backend, "ReadObject", start, err)
+	return content, err
+}
+
+func (s *photoStore) DeleteObject(objectKey string) error {
+	start := time.Now()
+	err := s.PhotoStore.DeleteObject(objectKey)
+	s.metrics.observeStore(s.backend, "DeleteObject", start, err)
+	return err
+}
+
+func (s *photoStore) Close() error {
+	if closer, ok := s.PhotoStore.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// avatarColors are the backgrounds of the generated avatars, all dark enough
+// for white initials to stay readable.
+var avatarColors = []string{
+	"#1565c0", "#2e7d32", "#6a1b9a", "#c62828", "#00695c", "#4527a0",
+	"#ad1457", "#283593", "#37474f", "#4e342e", "#00838f", "#bf360c",
+}
+
+// AvatarColor picks the background of the avatar of employeeId. It depends
+// on the id only, so an employee keeps the same color on every page and
+// after changing names.
+func AvatarColor(employeeId string) string {
+	h := fnv.New32a()
+	h.Write([]byte(employeeId))
+	return avatarColors[h.Sum32()%uint32(len(avatarColors))]
+}
+
+// Initials returns the first letter of the first and of the last word of
+// name, in upper case, e.g. "JS" for "Jane van der Smit".
+func Initials(name string) string {
+	var initials []rune
+	words := strings.Fields(name)
+	for i, word := range words {
+		if i != 0 && i != len(words)-1 {
+			continue
+		}
+		for _, r := range word {
+			if unicode.IsLetter(r) {
+				initials = append(initials, unicode.ToUpper(r))
+				break
+			}
+		}
+	}
+	return string(initials)
+}
+
+// Initials returns the initials of the employee, for the avatar shown when
+// there is no photo.
+func (e Employee) Initials() string {
+	return Initials(e.FullName)
+}
+
+// FindRendition returns the scaled rendition called name.
+func FindRendition(name string) (Rendition, bool) {
+	for _, r := range PhotoRenditions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rendition{}, false
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Types of the custom fields.
+const (
+	CustomText        = "text"
+	CustomNumber      = "number"
+	CustomDate        = "date"
+	CustomSelect      = "select"
+	CustomMultiselect = "multiselect"
+	CustomURL         = "url"
+)
+
+// Visibility of the custom fields. Public fields show on the employee page;
+// private ones are only left off it. The edit form, which anyone can open,
+// shows both, so private fields must not hold anything confidential.
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// CustomFieldTypes lists the types of custom field, with the name shown to
+// administrators.
+var CustomFieldTypes = map[string]string{
+	CustomText:        "Text",
+	CustomNumber:      "Number",
+	CustomDate:        "Date",
+	CustomSelect:      "Select",
+	CustomMultiselect: "Multiselect",
+	CustomURL:         "URL",
+}
+
+// MaxCustomFieldKey matches the varchar(64) key column of the SQL stores.
+const MaxCustomFieldKey = 64
+
+var customFieldKey = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// CustomField is a profile field defined by an administrator, such as the
+// desk number or the languages spoken. Employees store its values under Key.
+type CustomField struct {
+	Key        string   `dynamodbav:"key"`
+	Label      string   `dynamodbav:"label"`
+	Type       string   `dynamodbav:"type"`
+	Options    []string `dynamodbav:"options,omitempty"`
+	Required   bool     `dynamodbav:"required"`
+	Visibility string   `dynamodbav:"visibility"`
+	Position   int      `dynamodbav:"position"`
+}
+
+// Public tells whether the field shows on the employee page.
+func (c CustomField) Public() bool {
+	return c.Visibility == VisibilityPublic
+}
+
+// Normalize puts the label and options in the form values typed in the
+// employee form take, so select values compare equal to their options.
+// Options left the same by normalizing are kept once.
+func (c *CustomField) Normalize() {
+	c.Label = Normalize(c.Label)
+
+	var options []string
+	for _, o := range c.Options {
+		if o = Normalize(o); o != "" && !containsString(options, o) {
+			options = append(options, o)
+		}
+	}
+	c.Options = options
+}
+
+// HasOptions tells whether values are picked from Options.
+func (c CustomField) HasOptions() bool {
+	return c.Type == CustomSelect || c.Type == CustomMultiselect
+}
+
+// Validate checks the definition itself, as entered by an administrator.
+func (c CustomField) Validate() error {
+	var problems []string
+
+	if !customFieldKey.MatchString(c.Key) || len(c.Key) > MaxCustomFieldKey {
+		problems = append(problems, fmt.Sprintf("key must start with a lowercase letter and have only lowercase letters, digits and '_', up to %d characters", MaxCustomFieldKey))
+	}
+	if c.Label == "" {
+		problems = append(problems, "label is expected")
+	} else if err := MaxLength(MaxTextLength)(c.Label); err != nil {
+		problems = append(problems, "label "+err.Error())
+	}
+	if _, exist := CustomFieldTypes[c.Type]; !exist {
+		problems = append(problems, fmt.Sprintf("type '%s' is not supported", c.Type))
+	}
+	if c.HasOptions() && len(c.Options) == 0 {
+		problems = append(problems, "options are expected for select fields")
+	}
+	if c.Visibility != VisibilityPublic && c.Visibility != VisibilityPrivate {
+		problems = append(problems, fmt.Sprintf("visibility must be %s or %s", VisibilityPublic, VisibilityPrivate))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("custom field '%s' is invalid: %s", c.Key, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// rules returns the checks for a single value of the field.
+func (c CustomField) rules() []Rule {
+	switch c.Type {
+	case CustomNumber:
+		return []Rule{Number()}
+	case CustomDate:
+		return []Rule{Date()}
+	case CustomURL:
+		return []Rule{MaxLength(2000), URL()}
+	case CustomSelect, CustomMultiselect:
+		// for the definitions saved before their options were normalized
+		c.Normalize()
+		return []Rule{OneOf(c.Options)}
+	}
+	return []Rule{MaxLength(MaxTextLength), Printable()}
+}
+
+// SortCustomFields orders fields by position, then by label, as forms and
+// pages show them.
+func SortCustomFields(fields []CustomField) {
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].Position != fields[j].Position {
+			return fields[i].Position < fields[j].Position
+		}
+		return fields[i].Label < fields[j].Label
+	})
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCustomFieldNormalize(t *testing.T) {
+	field := CustomField{
+		Label:   "  Home   city ",
+		Type:    CustomSelect,
+		Options: []string{"  São   Paulo", "São Paulo", "", "Lima\r"},
+	}
+
+	field.Normalize()
+
+	if field.Label != "Home city" {
+		t.Errorf("Label = %q, want %q", field.Label, "Home city")
+	}
+	if want := []string{"São Paulo", "Lima"}; !reflect.DeepEqual(field.Options, want) {
+		t.Errorf("Options = %q, want %q", field.Options, want)
+	}
+}
+
+func TestCustomFieldSelectAcceptsNormalizedValues(t *testing.T) {
+	// saved before the options were normalized
+	field := CustomField{Key: "city", Type: CustomSelect, Options: []string{"São  Paulo"}}
+
+	for _, rule := range field.rules() {
+		if err := rule(Normalize("São Paulo")); err != nil {
+			t.Errorf("rule(%q) = %v, want the option accepted", "São Paulo", err)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 type Employee struct {
 	Id       string   `dynamodbav:"id"`
 	Photo    *Photo   `dynamodbav:"photo"`
@@ -7,11 +9,54 @@ type Employee struct {
 	Location string   `dynamodbav:"location"`
 	JobTitle string   `dynamodbav:"job_title"`
 	Badges   []string `dynamodbav:"badges"`
+	Profile
+}
+
+// Profile holds the optional contact and organization details of an
+// employee. Its attributes are stored next to the others in DynamoDB.
+type Profile struct {
+	Email  string   `dynamodbav:"email,omitempty"`
+	Phones []string `dynamodbav:"phones,omitempty"`
+	// Department is derived from the teams of kind department when the
+	// employee is saved. Records saved before keep a free-text one until
+	// then.
+	Department string            `dynamodbav:"department,omitempty"`
+	ManagerId  string            `dynamodbav:"manager_id,omitempty"`
+	StartDate  string            `dynamodbav:"start_date,omitempty"`
+	Pronouns   string            `dynamodbav:"pronouns,omitempty"`
+	Timezone   string            `dynamodbav:"timezone,omitempty"`
+	Bio        string            `dynamodbav:"bio,omitempty"`
+	Handles    map[string]string `dynamodbav:"handles,omitempty"`
+	// Teams holds the ids of the departments and teams the employee is a
+	// member of.
+	Teams []string `dynamodbav:"teams,omitempty"`
+	// Custom holds the values of the custom fields by key. Fields other
+	// than multiselect have a single value.
+	Custom map[string][]string `dynamodbav:"custom,omitempty"`
 }
 
+// Photo is the picture of an employee. ObjectKey is the key of its profile
+// rendition, see PhotoKey.
 type Photo struct {
 	ObjectKey string `dynamodbav:"object_key"`
 	SignedUrl string `dynamodbav:"-"`
+	// Renditions holds the signed url of each rendition, by name.
+	Renditions map[string]string `dynamodbav:"-"`
+}
+
+// BioLines splits the bio at its line breaks, so pages can show them.
+func (p Profile) BioLines() []string {
+	return strings.Split(p.Bio, "\n")
+}
+
+// CustomValue returns the value of a custom field as pages show it.
+func (p Profile) CustomValue(key string) string {
+	return strings.Join(p.Custom[key], ", ")
+}
+
+// InTeam tells whether the employee is a member of the team teamId.
+func (p Profile) InTeam(teamId string) bool {
+	return containsString(p.Teams, teamId)
 }
 
 func (e Employee) HasBadge(badge string) bool {
@@ -2,37 +2,166 @@ package model
 
 import (
 	"fmt"
+	"image"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
-	"net/http"
-	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/moura1001/aws-employee-directory-application/server/utils"
 )
 
 type Form struct {
-	EmployeeId Field
-	Photo      Field
-	FullName   Field
-	Location   Field
-	JobTitle   Field
-	Badges     Field
+	EmployeeId  Field
+	Photo       Field
+	PhotoCrop   Field
+	RemovePhoto Field
+	FullName    Field
+	Location    Field
+	JobTitle    Field
+	Badges      Field
+	Email       Field
+	Phones      Field
+	Manager     Field
+	StartDate   Field
+	Pronouns    Field
+	Timezone    Field
+	Bio         Field
+	Handles     Field
+	Teams       Field
+	Custom      []CustomInput
+
+	photoLimits PhotoLimits
+}
+
+// CustomInput is the form field of a custom field.
+type CustomInput struct {
+	Field
+	Definition CustomField
 }
 
+// MaxPhones limits how many numbers an employee can list, so they still fit
+// the nvarchar(200) column of the MySQL schema.
+const MaxPhones = 5
+
 type Field struct {
 	Name       string
 	Label      string
 	Data       interface{}
 	IsRequired bool
+	Rules      []Rule
+	Errors     []string
 }
 
 func NewForm() Form {
 	return Form{
-		EmployeeId: Field{IsRequired: false, Name: "employee_id"},
-		Photo:      Field{IsRequired: false, Name: "photo", Label: "Picture"},
-		FullName:   Field{IsRequired: true, Name: "full_name", Label: "Full Name"},
-		Location:   Field{IsRequired: true, Name: "location", Label: "Location"},
-		JobTitle:   Field{IsRequired: true, Name: "job_title", Label: "Job Title"},
-		Badges:     Field{IsRequired: false, Name: "badges", Label: "Badges"},
+		EmployeeId:  Field{IsRequired: false, Name: "employee_id", Label: "Employee Id", Rules: []Rule{MaxLength(64), Identifier()}},
+		Photo:       Field{IsRequired: false, Name: "photo", Label: "Picture"},
+		PhotoCrop:   Field{IsRequired: false, Name: "photo_crop", Label: "Crop", Rules: []Rule{CropBox()}},
+		RemovePhoto: Field{IsRequired: false, Name: "remove_photo", Label: "Remove picture", Data: false},
+		FullName:    Field{IsRequired: true, Name: "full_name", Label: "Full Name", Rules: []Rule{MaxLength(MaxTextLength), PersonName()}},
+		Location:    Field{IsRequired: true, Name: "location", Label: "Location", Rules: []Rule{MaxLength(MaxTextLength), Printable()}},
+		JobTitle:    Field{IsRequired: true, Name: "job_title", Label: "Job Title", Rules: []Rule{MaxLength(MaxTextLength), Printable()}},
+		Badges:      Field{IsRequired: false, Name: "badges", Label: "Badges"},
+		Email:       Field{IsRequired: false, Name: "email", Label: "Email", Rules: []Rule{MaxLength(254), Email()}},
+		Phones:      Field{IsRequired: false, Name: "phones", Label: "Phone Numbers", Data: []string{}, Rules: []Rule{MaxLength(30), PhoneNumber()}},
+		Manager:     Field{IsRequired: false, Name: "manager_id", Label: "Manager", Rules: []Rule{MaxLength(64), Identifier()}},
+		StartDate:   Field{IsRequired: false, Name: "start_date", Label: "Start Date", Rules: []Rule{Date()}},
+		Pronouns:    Field{IsRequired: false, Name: "pronouns", Label: "Pronouns", Rules: []Rule{MaxLength(40), Printable()}},
+		Timezone:    Field{IsRequired: false, Name: "timezone", Label: "Time Zone", Rules: []Rule{MaxLength(64), Timezone()}},
+		Bio:         Field{IsRequired: false, Name: "bio", Label: "Bio", Rules: []Rule{MaxLength(2000), MultilineText()}},
+		Handles:     Field{IsRequired: false, Name: "handle_", Label: "Handles", Data: map[string]string{}, Rules: []Rule{MaxLength(100), Handle()}},
+		Teams:       Field{IsRequired: false, Name: "teams", Label: "Teams", Data: []string{}, Rules: []Rule{MaxLength(64), Identifier()}},
+
+		photoLimits: DefaultPhotoLimits,
+	}
+}
+
+// NewEmployeeForm returns an empty form filled with the values of employee,
+// for editing.
+func NewEmployeeForm(employee *Employee) Form {
+	f := NewForm()
+	f.EmployeeId.Data = employee.Id
+	f.FullName.Data = employee.FullName
+	f.Location.Data = employee.Location
+	f.JobTitle.Data = employee.JobTitle
+	if len(employee.Badges) > 0 {
+		f.Badges.Data = employee.Badges
+	}
+	f.Email.Data = employee.Email
+	if len(employee.Phones) > 0 {
+		f.Phones.Data = employee.Phones
+	}
+	f.Manager.Data = employee.ManagerId
+	f.StartDate.Data = employee.StartDate
+	f.Pronouns.Data = employee.Pronouns
+	f.Timezone.Data = employee.Timezone
+	f.Bio.Data = employee.Bio
+	if len(employee.Handles) > 0 {
+		f.Handles.Data = employee.Handles
+	}
+	if len(employee.Teams) > 0 {
+		f.Teams.Data = employee.Teams
+	}
+	return f
+}
+
+// SetCustomFields adds an input per custom field, filled with values, e.g.
+// the ones stored for the employee being edited.
+func (f *Form) SetCustomFields(fields []CustomField, values map[string][]string) {
+	f.Custom = make([]CustomInput, 0, len(fields))
+	for _, def := range fields {
+		input := CustomInput{
+			Field: Field{
+				Name:       "custom_" + def.Key,
+				Label:      def.Label,
+				IsRequired: def.Required,
+				Rules:      def.rules(),
+			},
+			Definition: def,
+		}
+		if def.Type == CustomMultiselect {
+			input.Data = append([]string{}, values[def.Key]...)
+		} else if len(values[def.Key]) > 0 {
+			input.Data = values[def.Key][0]
+		}
+		f.Custom = append(f.Custom, input)
+	}
+}
+
+// SetPhotoLimits replaces the bounds of the pictures the form accepts.
+func (f *Form) SetPhotoLimits(limits PhotoLimits) {
+	f.photoLimits = limits
+}
+
+// PhotoLimits returns the bounds of the pictures the form accepts.
+func (f Form) PhotoLimits() PhotoLimits {
+	return f.photoLimits
+}
+
+// Profile returns the profile of a validated form.
+func (f Form) Profile() Profile {
+	custom := map[string][]string{}
+	for _, input := range f.Custom {
+		if values, multiple := input.Data.([]string); multiple && len(values) > 0 {
+			custom[input.Definition.Key] = values
+		} else if value := input.ToString(); !multiple && value != "" {
+			custom[input.Definition.Key] = []string{value}
+		}
+	}
+
+	return Profile{
+		Email:     f.Email.ToString(),
+		Phones:    f.Phones.Data.([]string),
+		ManagerId: f.Manager.ToString(),
+		StartDate: f.StartDate.ToString(),
+		Pronouns:  f.Pronouns.ToString(),
+		Timezone:  f.Timezone.ToString(),
+		Bio:       f.Bio.ToString(),
+		Handles:   f.Handles.Data.(map[string]string),
+		Teams:     f.Teams.Data.([]string),
+		Custom:    custom,
 	}
 }
 
@@ -49,6 +178,155 @@ func (f Field) Contains(key string) bool {
 	return false
 }
 
+// Lines returns a list value one item per line, as textareas show it.
+func (f Field) Lines() string {
+	s, _ := f.Data.([]string)
+	return strings.Join(s, "\n")
+}
+
+// Handle returns the value of one entry of a map field, such as the github
+// handle.
+func (f Field) Handle(key string) string {
+	m, _ := f.Data.(map[string]string)
+	return m[key]
+}
+
+// Valid tells whether the last validation found no problem with the field.
+func (f Field) Valid() bool {
+	return len(f.Errors) == 0
+}
+
+// validateText normalizes the submitted value, keeps it in Data even when it
+// is invalid, so the user can fix it, and records every rule it breaks.
+func (f *Field) validateText(values []string) {
+	value := ""
+	if len(values) > 0 {
+		value = Normalize(values[0])
+	}
+	f.Data = value
+
+	if value == "" {
+		if f.IsRequired {
+			f.fail("'%s' field is expected", f.Label)
+		}
+		return
+	}
+
+	f.check(fmt.Sprintf("'%s'", f.Label), value)
+}
+
+// validateMultiline is validateText for textareas, where line breaks are
+// part of the value.
+func (f *Field) validateMultiline(values []string) {
+	value := ""
+	if len(values) > 0 {
+		value = normalizeLines(values[0])
+	}
+	f.Data = value
+
+	if value == "" {
+		if f.IsRequired {
+			f.fail("'%s' field is expected", f.Label)
+		}
+		return
+	}
+
+	f.check(fmt.Sprintf("'%s'", f.Label), value)
+}
+
+// validateList takes one item per line, as typed in a textarea, and checks
+// each of them against the rules. Blank lines and repeated items are
+// dropped.
+func (f *Field) validateList(values []string, max int) {
+	items := []string{}
+	if len(values) > 0 {
+		for _, line := range strings.Split(values[0], "\n") {
+			if item := Normalize(line); item != "" && !containsString(items, item) {
+				items = append(items, item)
+			}
+		}
+	}
+	f.Data = items
+
+	if len(items) == 0 && f.IsRequired {
+		f.fail("'%s' field is expected", f.Label)
+	}
+	if len(items) > max {
+		f.fail("'%s' may have at most %d items, got %d", f.Label, max, len(items))
+	}
+	for _, item := range items {
+		f.check(fmt.Sprintf("'%s' %q", f.Label, item), item)
+	}
+}
+
+// validateChoices takes the checked options of a multiselect, dropping the
+// repeated ones.
+func (f *Field) validateChoices(values []string) {
+	items := []string{}
+	for _, v := range values {
+		if item := Normalize(v); item != "" && !containsString(items, item) {
+			items = append(items, item)
+		}
+	}
+	f.Data = items
+
+	if len(items) == 0 && f.IsRequired {
+		f.fail("'%s' field is expected", f.Label)
+	}
+	for _, item := range items {
+		f.check(fmt.Sprintf("'%s' %q", f.Label, item), item)
+	}
+}
+
+// validateMap reads one input per key, named after the field name plus the
+// key, e.g. handle_github, and keeps only the filled ones.
+func (f *Field) validateMap(form map[string][]string, keys map[string]string, trim string) {
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	data := map[string]string{}
+	for _, key := range sorted {
+		label := keys[key]
+		values := form[f.Name+key]
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.TrimPrefix(Normalize(values[0]), trim)
+		if value == "" {
+			continue
+		}
+		data[key] = value
+		f.check(fmt.Sprintf("'%s' (%s)", f.Label, label), value)
+	}
+	f.Data = data
+}
+
+// check runs the rules on value, naming it subject in the errors.
+func (f *Field) check(subject, value string) {
+	for _, rule := range f.Rules {
+		if err := rule(value); err != nil {
+			f.fail("%s %v", subject, err)
+		}
+	}
+}
+
+// AddError records a problem found outside of the form, such as a manager
+// that does not exist.
+func (f *Field) AddError(format string, args ...interface{}) {
+	f.fail(format, args...)
+}
+
+// fail records a validation error on the field, so the form can be shown
+// again with the message next to the input, and returns it.
+func (f *Field) fail(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	f.Errors = append(f.Errors, err.Error())
+	return err
+}
+
 func (f Field) ToString() (value string) {
 	switch t := f.Data.(type) {
 	case string:
@@ -64,62 +342,157 @@ func (f Field) ToString() (value string) {
 	return
 }
 
+// ValidateOnSubmit checks every field, instead of stopping at the first
+// problem, so the form can be shown again with all the errors at once.
 func (f *Form) ValidateOnSubmit(form *multipart.Form) error {
-	space := regexp.MustCompile(`\s+`)
+	f.EmployeeId.validateText(form.Value[f.EmployeeId.Name])
+	f.FullName.validateText(form.Value[f.FullName.Name])
+	f.Location.validateText(form.Value[f.Location.Name])
+	f.JobTitle.validateText(form.Value[f.JobTitle.Name])
 
-	employeeId := form.Value[f.EmployeeId.Name][0]
-	fullName := strings.TrimSpace(form.Value[f.FullName.Name][0])
-	location := strings.TrimSpace(form.Value[f.Location.Name][0])
-	jobTitle := strings.TrimSpace(form.Value[f.JobTitle.Name][0])
 	badges := []string{}
-
-	f.EmployeeId.Data = employeeId
-	if fullName != "" {
-		f.FullName.Data = space.ReplaceAllString(fullName, " ")
-	} else {
-		return fmt.Errorf("'%s' field is expected", f.FullName.Label)
-	}
-	if location != "" {
-		f.Location.Data = space.ReplaceAllString(location, " ")
-	} else {
-		return fmt.Errorf("'%s' field is expected", f.Location.Label)
-	}
-	if jobTitle != "" {
-		f.JobTitle.Data = space.ReplaceAllString(jobTitle, " ")
-	} else {
-		return fmt.Errorf("'%s' field is expected", f.JobTitle.Label)
-	}
+	seen := map[string]bool{}
 	for _, b := range form.Value[f.Badges.Name] {
 		v := strings.TrimSpace(b)
 		_, exist := Badges[v]
-		if exist {
+		if exist && !seen[v] {
 			badges = append(badges, v)
+			seen[v] = true
 		}
 	}
 	f.Badges.Data = badges
 
-	if len(form.File[f.Photo.Name]) > 0 {
-		file := form.File[f.Photo.Name][0]
-		if file != nil {
-			content, err := file.Open()
-			if err != nil {
-				return fmt.Errorf("error to open '%s' field", f.Photo.Label)
-			}
-			defer content.Close()
-
-			fi, err := ioutil.ReadAll(content)
-			if err != nil {
-				return fmt.Errorf("error to read '%s' field data", f.Photo.Label)
-			}
+	f.Email.validateText(form.Value[f.Email.Name])
+	f.Phones.validateList(form.Value[f.Phones.Name], MaxPhones)
+	f.Manager.validateText(form.Value[f.Manager.Name])
+	f.StartDate.validateText(form.Value[f.StartDate.Name])
+	f.Pronouns.validateText(form.Value[f.Pronouns.Name])
+	f.Timezone.validateText(form.Value[f.Timezone.Name])
+	f.Bio.validateMultiline(form.Value[f.Bio.Name])
+	f.Handles.validateMap(form.Value, HandleTypes, "@")
+	f.Teams.validateChoices(form.Value[f.Teams.Name])
+	if manager := f.Manager.ToString(); manager != "" && manager == f.EmployeeId.ToString() {
+		f.Manager.fail("'%s' must be someone else", f.Manager.Label)
+	}
 
-			fiType := http.DetectContentType(fi)
-			if strings.HasPrefix(fiType, "image") {
-				f.Photo.Data = fi
-			} else {
-				return fmt.Errorf("'%s' field file must be a valid image", f.Photo.Label)
-			}
+	for i := range f.Custom {
+		input := &f.Custom[i]
+		if input.Definition.Type == CustomMultiselect {
+			input.validateChoices(form.Value[input.Name])
+		} else {
+			input.validateText(form.Value[input.Name])
 		}
 	}
 
+	f.PhotoCrop.validateText(form.Value[f.PhotoCrop.Name])
+	if len(form.File[f.Photo.Name]) > 0 {
+		f.validatePhoto(form.File[f.Photo.Name][0])
+	}
+	if f.Photo.Data == nil {
+		// a crop without a new picture has nothing to apply to
+		f.PhotoCrop.Data = nil
+	}
+	f.RemovePhoto.Data = len(form.Value[f.RemovePhoto.Name]) > 0
+
+	return f.Err()
+}
+
+// RemovesPhoto tells whether the picture of the employee is to be removed,
+// which a new picture, replacing it anyway, takes precedence over.
+func (f Form) RemovesPhoto() bool {
+	remove, _ := f.RemovePhoto.Data.(bool)
+	return remove && f.Photo.Data == nil
+}
+
+// Err returns the problems recorded on the fields, including those added
+// after ValidateOnSubmit, or nil when there is none.
+func (f Form) Err() error {
+	return fieldsErr(f.fields())
+}
+
+func fieldsErr(fields []Field) error {
+	var problems []string
+	for _, field := range fields {
+		problems = append(problems, field.Errors...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("form failed validation: %s", strings.Join(problems, "; "))
+	}
 	return nil
 }
+
+func (f Form) fields() []Field {
+	fields := []Field{
+		f.EmployeeId, f.Photo, f.PhotoCrop, f.FullName, f.Location, f.JobTitle, f.Badges,
+		f.Email, f.Phones, f.Manager, f.StartDate, f.Pronouns, f.Timezone, f.Bio, f.Handles, f.Teams,
+	}
+	for _, input := range f.Custom {
+		fields = append(fields, input.Field)
+	}
+	return fields
+}
+
+// validatePhoto checks the uploaded picture against the photo limits and
+// keeps it, decoded and encoded again without its metadata, as the data of
+// the field.
+func (f *Form) validatePhoto(file *multipart.FileHeader) {
+	if file == nil {
+		return
+	}
+
+	limits := f.photoLimits
+	if file.Size > limits.MaxBytes {
+		f.Photo.fail("'%s' field file must be at most %s", f.Photo.Label, FormatBytes(limits.MaxBytes))
+		return
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		f.Photo.fail("error to open '%s' field", f.Photo.Label)
+		return
+	}
+	defer content.Close()
+
+	fi, err := ioutil.ReadAll(io.LimitReader(content, limits.MaxBytes+1))
+	if err != nil {
+		f.Photo.fail("error to read '%s' field data", f.Photo.Label)
+		return
+	}
+	if int64(len(fi)) > limits.MaxBytes {
+		f.Photo.fail("'%s' field file must be at most %s", f.Photo.Label, FormatBytes(limits.MaxBytes))
+		return
+	}
+
+	// the format is told by the first bytes, not by the name or the type
+	// the browser sent, and only the headers are read until the size is
+	// known to be within bounds
+	format, width, height, err := utils.ImageConfig(fi)
+	if err != nil || !limits.allows(format) {
+		f.Photo.fail("'%s' field file must be a %s image", f.Photo.Label, limits.formatNames())
+		return
+	}
+	if width > limits.MaxWidth || height > limits.MaxHeight {
+		f.Photo.fail("'%s' field picture must be at most %dx%d pixels, got %dx%d",
+			f.Photo.Label, limits.MaxWidth, limits.MaxHeight, width, height)
+		return
+	}
+
+	clean, err := utils.CleanImage(fi)
+	if err != nil {
+		f.Photo.fail("'%s' field file is damaged or truncated", f.Photo.Label)
+		return
+	}
+	f.Photo.Data = clean
+
+	crop := f.Crop()
+	if !crop.Empty() && !crop.Overlaps(image.Rect(0, 0, width, height)) {
+		f.PhotoCrop.fail("'%s' box is outside the %dx%d picture", f.PhotoCrop.Label, width, height)
+	}
+}
+
+// Crop returns the part of the uploaded picture to keep, or an empty
+// rectangle for the whole picture.
+func (f Form) Crop() image.Rectangle {
+	crop, _ := parseCrop(f.PhotoCrop.ToString())
+	return crop
+}
@@ -0,0 +1,10 @@
+package model
+
+// HandleTypes are the chat and social networks an employee can list a
+// handle for, keyed by the Font Awesome icon of each.
+var HandleTypes = map[string]string{
+	"slack":    "Slack",
+	"github":   "GitHub",
+	"linkedin": "LinkedIn",
+	"twitter":  "Twitter",
+}
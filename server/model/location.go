@@ -0,0 +1,167 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// LocationCount is one spelling of a location and how many employees use
+// it.
+type LocationCount struct {
+	Value string
+	Count int
+}
+
+// LocationCluster groups the spellings that most likely name the same
+// place, such as "Seattle", "seattle, WA" and "SEA", so an administrator can
+// merge them into one.
+type LocationCluster struct {
+	Values []LocationCount
+	// Office is the office the cluster matches by name, if any.
+	Office *Office
+}
+
+// Target is the spelling the cluster should be merged into: the office
+// name when there is one, otherwise the most used value.
+func (c LocationCluster) Target() string {
+	if c.Office != nil {
+		return c.Office.Name
+	}
+	return c.Values[0].Value
+}
+
+// Clean tells whether the cluster needs no merging, that is it has a single
+// spelling which is also the name of an office.
+func (c LocationCluster) Clean() bool {
+	return len(c.Values) == 1 && c.Office != nil && c.Values[0].Value == c.Office.Name
+}
+
+// Total counts the employees in every spelling of the cluster.
+func (c LocationCluster) Total() int {
+	total := 0
+	for _, v := range c.Values {
+		total += v.Count
+	}
+	return total
+}
+
+// ClusterLocations groups the locations of employees by a loose key: case,
+// accents, punctuation and anything after a comma are ignored, so "São
+// Paulo, SP" and "sao paulo" fall together. Short all-capital values such as
+// "SEA" join the cluster whose key starts with them, see abbreviationMatch.
+// Office names seed the clusters, so offices no one uses yet are offered as
+// targets too.
+func ClusterLocations(employees []*Employee, offices []*Office) []LocationCluster {
+	counts := map[string]int{}
+	for _, e := range employees {
+		if e.Location != "" {
+			counts[e.Location]++
+		}
+	}
+
+	clusters := map[string]*LocationCluster{}
+	cluster := func(key string) *LocationCluster {
+		if clusters[key] == nil {
+			clusters[key] = &LocationCluster{}
+		}
+		return clusters[key]
+	}
+
+	for _, o := range offices {
+		if c := cluster(locationKey(o.Name)); c.Office == nil {
+			c.Office = o
+		}
+	}
+
+	values := make([]string, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	var abbreviations []string
+	for _, v := range values {
+		if isAbbreviation(v) {
+			abbreviations = append(abbreviations, v)
+			continue
+		}
+		c := cluster(locationKey(v))
+		c.Values = append(c.Values, LocationCount{Value: v, Count: counts[v]})
+	}
+
+	for _, v := range abbreviations {
+		key := locationKey(v)
+		var matches []string
+		for k := range clusters {
+			if k != key && strings.HasPrefix(k, key) {
+				matches = append(matches, k)
+			}
+		}
+		c := cluster(abbreviationMatch(key, matches))
+		c.Values = append(c.Values, LocationCount{Value: v, Count: counts[v]})
+	}
+
+	res := make([]LocationCluster, 0, len(clusters))
+	for _, c := range clusters {
+		if len(c.Values) == 0 {
+			continue
+		}
+		sort.SliceStable(c.Values, func(i, j int) bool {
+			return c.Values[i].Count > c.Values[j].Count
+		})
+		res = append(res, *c)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return strings.ToLower(res[i].Target()) < strings.ToLower(res[j].Target())
+	})
+
+	return res
+}
+
+// locationKey folds a location into the key ClusterLocations compares.
+func locationKey(value string) string {
+	if i := strings.IndexAny(value, ",("); i >= 0 {
+		value = value[:i]
+	}
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// abbreviationMatch picks the cluster key an abbreviation belongs to among
+// the keys starting with it. Keys that extend one another, as "seattle" and
+// "seattlehq", count as a single match, the shortest; otherwise the
+// abbreviation is ambiguous and keeps its own key.
+func abbreviationMatch(key string, matches []string) string {
+	if len(matches) == 0 {
+		return key
+	}
+	sort.Strings(matches)
+	for _, m := range matches[1:] {
+		if !strings.HasPrefix(m, matches[0]) {
+			return key
+		}
+	}
+	return matches[0]
+}
+
+// isAbbreviation tells whether value looks like an airport or city code.
+func isAbbreviation(value string) bool {
+	if len(value) < 2 || len(value) > 4 {
+		return false
+	}
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocationKey(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Seattle", "seattle"},
+		{"seattle, WA", "seattle"},
+		{"Seattle (HQ)", "seattle"},
+		{"São Paulo", "saopaulo"},
+		{"Sa\u0303o Paulo", "saopaulo"},
+		{"Sao Paulo, SP", "saopaulo"},
+		{"SEA", "sea"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := locationKey(tt.value); got != tt.want {
+				t.Errorf("locationKey(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAbbreviation(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"SEA", true},
+		{"WA", true},
+		{"NYC", true},
+		{"S", false},
+		{"SEATT", false},
+		{"sea", false},
+		{"Sea", false},
+		{"SÃO", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := isAbbreviation(tt.value); got != tt.want {
+				t.Errorf("isAbbreviation(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterLocations(t *testing.T) {
+	var employees []*Employee
+	for _, location := range []string{
+		"Seattle", "Seattle", "seattle, WA", "SEA",
+		"São Paulo", "São Paulo", "Sao Paulo", "Sao Paulo, SP",
+		"Lisbon",
+	} {
+		employees = append(employees, &Employee{Location: location})
+	}
+	offices := []*Office{{Id: "1", Name: "Seattle"}, {Id: "2", Name: "Lisbon"}, {Id: "3", Name: "Tokyo"}}
+
+	clusters := ClusterLocations(employees, offices)
+
+	var got [][]LocationCount
+	var targets []string
+	for _, c := range clusters {
+		got = append(got, c.Values)
+		targets = append(targets, c.Target())
+	}
+	want := [][]LocationCount{
+		{{"Lisbon", 1}},
+		{{"Seattle", 2}, {"seattle, WA", 1}, {"SEA", 1}},
+		{{"São Paulo", 2}, {"Sao Paulo", 1}, {"Sao Paulo, SP", 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ClusterLocations() = %v, want %v", got, want)
+	}
+	if want := []string{"Lisbon", "Seattle", "São Paulo"}; !reflect.DeepEqual(targets, want) {
+		t.Errorf("targets = %q, want %q", targets, want)
+	}
+	if !clusters[0].Clean() || clusters[1].Clean() || clusters[2].Clean() {
+		t.Errorf("only the Lisbon cluster should be clean")
+	}
+}
+
+func TestClusterLocationsAmbiguousAbbreviation(t *testing.T) {
+	var employees []*Employee
+	for _, location := range []string{"Santiago", "San Jose", "SAN"} {
+		employees = append(employees, &Employee{Location: location})
+	}
+
+	clusters := ClusterLocations(employees, nil)
+
+	if len(clusters) != 3 {
+		t.Errorf("ClusterLocations() = %v, want SAN on its own", clusters)
+	}
+}
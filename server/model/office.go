@@ -0,0 +1,143 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Office is a place employees work from. Employees are in an office when
+// their Location is the office name; other locations are still accepted,
+// as free text.
+type Office struct {
+	Id        string   `dynamodbav:"-"`
+	Name      string   `dynamodbav:"name"`
+	Address   string   `dynamodbav:"address,omitempty"`
+	Timezone  string   `dynamodbav:"timezone,omitempty"`
+	Latitude  *float64 `dynamodbav:"latitude,omitempty"`
+	Longitude *float64 `dynamodbav:"longitude,omitempty"`
+}
+
+// AddressLines splits the address at its line breaks, so pages can show
+// them.
+func (o Office) AddressLines() []string {
+	return strings.Split(o.Address, "\n")
+}
+
+// MapURL links to the office on OpenStreetMap, or is empty when the
+// coordinates are unknown.
+func (o Office) MapURL() string {
+	if o.Latitude == nil || o.Longitude == nil {
+		return ""
+	}
+	return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%g&mlon=%g#map=16/%g/%g", *o.Latitude, *o.Longitude, *o.Latitude, *o.Longitude)
+}
+
+// LocalTime returns the current time at the office, or an empty string
+// when its time zone is unknown.
+func (o Office) LocalTime() string {
+	loc, err := time.LoadLocation(o.Timezone)
+	if o.Timezone == "" || err != nil {
+		return ""
+	}
+	return time.Now().In(loc).Format("Mon 15:04 MST")
+}
+
+// SortOffices orders offices by name, as lists and selectors show them.
+func SortOffices(offices []*Office) {
+	sort.SliceStable(offices, func(i, j int) bool {
+		return offices[i].Name < offices[j].Name
+	})
+}
+
+// FindOffice returns the office called name, or nil.
+func FindOffice(offices []*Office, name string) *Office {
+	for _, o := range offices {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+type OfficeForm struct {
+	OfficeId  Field
+	Name      Field
+	Address   Field
+	Timezone  Field
+	Latitude  Field
+	Longitude Field
+}
+
+func NewOfficeForm() OfficeForm {
+	return OfficeForm{
+		OfficeId:  Field{IsRequired: false, Name: "office_id", Label: "Office Id", Rules: []Rule{MaxLength(64), Identifier()}},
+		Name:      Field{IsRequired: true, Name: "name", Label: "Name", Rules: []Rule{MaxLength(MaxTextLength), Printable()}},
+		Address:   Field{IsRequired: false, Name: "address", Label: "Address", Rules: []Rule{MaxLength(500), MultilineText()}},
+		Timezone:  Field{IsRequired: false, Name: "timezone", Label: "Time Zone", Rules: []Rule{MaxLength(64), Timezone()}},
+		Latitude:  Field{IsRequired: false, Name: "latitude", Label: "Latitude", Rules: []Rule{Number(), Between(-90, 90)}},
+		Longitude: Field{IsRequired: false, Name: "longitude", Label: "Longitude", Rules: []Rule{Number(), Between(-180, 180)}},
+	}
+}
+
+// NewOfficeEditForm returns an empty form filled with the values of office,
+// for editing.
+func NewOfficeEditForm(office *Office) OfficeForm {
+	f := NewOfficeForm()
+	f.OfficeId.Data = office.Id
+	f.Name.Data = office.Name
+	f.Address.Data = office.Address
+	f.Timezone.Data = office.Timezone
+	if office.Latitude != nil {
+		f.Latitude.Data = strconv.FormatFloat(*office.Latitude, 'f', -1, 64)
+	}
+	if office.Longitude != nil {
+		f.Longitude.Data = strconv.FormatFloat(*office.Longitude, 'f', -1, 64)
+	}
+	return f
+}
+
+// ValidateOnSubmit checks every field of the submitted values, as
+// Form.ValidateOnSubmit does. Coordinates come in pairs.
+func (f *OfficeForm) ValidateOnSubmit(values map[string][]string) error {
+	f.OfficeId.validateText(values[f.OfficeId.Name])
+	f.Name.validateText(values[f.Name.Name])
+	f.Address.validateMultiline(values[f.Address.Name])
+	f.Timezone.validateText(values[f.Timezone.Name])
+	f.Latitude.validateText(values[f.Latitude.Name])
+	f.Longitude.validateText(values[f.Longitude.Name])
+
+	if (f.Latitude.ToString() == "") != (f.Longitude.ToString() == "") {
+		f.Longitude.fail("'%s' and '%s' must be given together", f.Latitude.Label, f.Longitude.Label)
+	}
+
+	return f.Err()
+}
+
+// Office returns the office of a validated form.
+func (f OfficeForm) Office() Office {
+	return Office{
+		Id:        f.OfficeId.ToString(),
+		Name:      f.Name.ToString(),
+		Address:   f.Address.ToString(),
+		Timezone:  f.Timezone.ToString(),
+		Latitude:  parseCoordinate(f.Latitude.ToString()),
+		Longitude: parseCoordinate(f.Longitude.ToString()),
+	}
+}
+
+// Err returns the problems recorded on the fields, or nil when there is
+// none.
+func (f OfficeForm) Err() error {
+	return fieldsErr([]Field{f.OfficeId, f.Name, f.Address, f.Timezone, f.Latitude, f.Longitude})
+}
+
+func parseCoordinate(value string) *float64 {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,93 @@
+package model
+
+import "sort"
+
+// OrgNode is an employee of the organization chart with the people who
+// report to them.
+type OrgNode struct {
+	Employee *Employee
+	Reports  []*OrgNode
+}
+
+// Size counts the employees under the node, the node excluded.
+func (n *OrgNode) Size() int {
+	size := len(n.Reports)
+	for _, r := range n.Reports {
+		size += r.Size()
+	}
+	return size
+}
+
+// OrgChart arranges employees by their managers. Employees without a
+// manager, or whose manager is not in the list, are the roots. A reporting
+// cycle has no root, so one of its members is taken as one.
+func OrgChart(employees []*Employee) []*OrgNode {
+	byId := make(map[string]*Employee, len(employees))
+	for _, e := range employees {
+		byId[e.Id] = e
+	}
+
+	reports := map[string][]*Employee{}
+	var roots []*Employee
+	for _, e := range employees {
+		if _, exist := byId[e.ManagerId]; e.ManagerId != "" && exist {
+			reports[e.ManagerId] = append(reports[e.ManagerId], e)
+		} else {
+			roots = append(roots, e)
+		}
+	}
+	SortEmployees(roots)
+
+	visited := map[string]bool{}
+	chart := []*OrgNode{}
+	for _, e := range roots {
+		chart = append(chart, buildNode(e, reports, visited))
+	}
+
+	var unvisited []*Employee
+	for _, e := range employees {
+		if !visited[e.Id] {
+			unvisited = append(unvisited, e)
+		}
+	}
+	SortEmployees(unvisited)
+	for _, e := range unvisited {
+		if !visited[e.Id] {
+			chart = append(chart, buildNode(e, reports, visited))
+		}
+	}
+
+	return chart
+}
+
+// OrgTree returns the subtree under root, given the employees found by
+// the store below it.
+func OrgTree(root *Employee, subordinates []*Employee) *OrgNode {
+	reports := map[string][]*Employee{}
+	for _, e := range subordinates {
+		reports[e.ManagerId] = append(reports[e.ManagerId], e)
+	}
+	return buildNode(root, reports, map[string]bool{})
+}
+
+func buildNode(e *Employee, reports map[string][]*Employee, visited map[string]bool) *OrgNode {
+	visited[e.Id] = true
+	node := &OrgNode{Employee: e, Reports: []*OrgNode{}}
+
+	direct := reports[e.Id]
+	SortEmployees(direct)
+	for _, r := range direct {
+		if !visited[r.Id] {
+			node.Reports = append(node.Reports, buildNode(r, reports, visited))
+		}
+	}
+	return node
+}
+
+// SortEmployees orders employees by name, as the chart and member lists
+// show them.
+func SortEmployees(employees []*Employee) {
+	sort.SliceStable(employees, func(i, j int) bool {
+		return employees[i].FullName < employees[j].FullName
+	})
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func employee(id, name, managerId string) *Employee {
+	return &Employee{Id: id, FullName: name, Profile: Profile{ManagerId: managerId}}
+}
+
+// names lists the employees of the tree depth first, indented by level.
+func names(node *OrgNode, depth int) []string {
+	line := ""
+	for i := 0; i < depth; i++ {
+		line += "  "
+	}
+	res := []string{line + node.Employee.FullName}
+	for _, r := range node.Reports {
+		res = append(res, names(r, depth+1)...)
+	}
+	return res
+}
+
+func TestOrgTree(t *testing.T) {
+	root := employee("1", "Ana", "")
+	subordinates := []*Employee{
+		employee("4", "Dan", "2"),
+		employee("3", "Cid", "1"),
+		employee("2", "Bea", "1"),
+		employee("5", "Eva", "2"),
+	}
+
+	tree := OrgTree(root, subordinates)
+
+	want := []string{"Ana", "  Bea", "    Dan", "    Eva", "  Cid"}
+	if got := names(tree, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("OrgTree() = %q, want %q", got, want)
+	}
+	if size := tree.Size(); size != 4 {
+		t.Errorf("Size() = %d, want 4", size)
+	}
+}
+
+func TestOrgTreeStopsAtCycles(t *testing.T) {
+	// in a cycle the recursive queries find the root among its own
+	// subordinates
+	root := employee("1", "Ana", "3")
+	subordinates := []*Employee{
+		employee("2", "Bea", "1"),
+		employee("3", "Cid", "2"),
+		employee("1", "Ana", "3"),
+	}
+
+	tree := OrgTree(root, subordinates)
+
+	want := []string{"Ana", "  Bea", "    Cid"}
+	if got := names(tree, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("OrgTree() = %q, want %q", got, want)
+	}
+}
+
+func TestOrgChart(t *testing.T) {
+	employees := []*Employee{
+		employee("1", "Ana", ""),
+		employee("2", "Bea", "1"),
+		employee("3", "Cid", "gone"),
+		employee("4", "Dan", "5"),
+		employee("5", "Eva", "4"),
+	}
+
+	chart := OrgChart(employees)
+
+	var got []string
+	for _, node := range chart {
+		got = append(got, names(node, 0)...)
+	}
+	// Cid's manager left, and the Dan/Eva cycle has no root, so Dan is taken
+	want := []string{"Ana", "  Bea", "Cid", "Dan", "  Eva"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OrgChart() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+package model
+
+import (
+	"fmt"
+	"image"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/moura1001/aws-employee-directory-application/server/utils"
+)
+
+// Names of the renditions of a photo. The original is kept as uploaded,
+// less its metadata; the others are cropped and scaled to the sizes in
+// PhotoRenditions.
+const (
+	RenditionThumbnail = "thumbnail"
+	RenditionProfile   = "profile"
+	RenditionOriginal  = "original"
+)
+
+// Rendition is a scaled copy of a photo, cut to the 3:4 portrait shape.
+type Rendition struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// PhotoRenditions are the scaled renditions generated on upload, smallest
+// first. Each is twice the size pages show it at, for high density screens.
+var PhotoRenditions = []Rendition{
+	{Name: RenditionThumbnail, Width: 120, Height: 160},
+	{Name: RenditionProfile, Width: 480, Height: 640},
+}
+
+// PhotoLimits bound the pictures the edit form accepts.
+type PhotoLimits struct {
+	// Formats are the allowed formats, among the keys of utils.ImageTypes.
+	Formats   []string
+	MaxBytes  int64
+	MaxWidth  int
+	MaxHeight int
+}
+
+// DefaultPhotoLimits fit the photos of today's phones.
+var DefaultPhotoLimits = PhotoLimits{
+	Formats:   []string{"jpeg", "png", "gif"},
+	MaxBytes:  5 << 20,
+	MaxWidth:  6000,
+	MaxHeight: 6000,
+}
+
+func (l PhotoLimits) allows(format string) bool {
+	for _, f := range l.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// formatNames lists the allowed formats for messages, e.g. "JPEG or PNG".
+func (l PhotoLimits) formatNames() string {
+	names := make([]string, len(l.Formats))
+	for i, f := range l.Formats {
+		names[i] = strings.ToUpper(f)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// Accept lists the content types of the allowed formats, for the accept
+// attribute of a file input.
+func (l PhotoLimits) Accept() string {
+	types := make([]string, 0, len(l.Formats))
+	for _, f := range l.Formats {
+		types = append(types, utils.ImageTypes[f])
+	}
+	return strings.Join(types, ",")
+}
+
+// String describes the limits to people choosing a picture.
+func (l PhotoLimits) String() string {
+	return fmt.Sprintf("%s, up to %s and %dx%d pixels.", l.formatNames(), FormatBytes(l.MaxBytes), l.MaxWidth, l.MaxHeight)
+}
+
+// FormatBytes writes n in the largest unit it has at least one of, e.g.
+// "5 MB" for 5<<20.
+func FormatBytes(n int64) string {
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if n >= unit.size {
+			return strconv.FormatFloat(float64(n)/float64(unit.size), 'f', -1, 64) + " " + unit.name
+		}
+	}
+	return strconv.FormatInt(n, 10) + " bytes"
+}
+
+// PhotoKey returns the object key of a rendition of the photo of employeeId.
+// version identifies the uploaded content, so a new picture gets new keys
+// and browsers may cache the old ones for good. The renditions of a picture
+// share a prefix, so the one stored on the employee, the profile rendition,
+// is enough to find the others.
+func PhotoKey(employeeId, version, rendition string) string {
+	return "employee_pic/" + employeeId + "/" + version + "/" + renditionFile(rendition)
+}
+
+// PhotoVersion returns the version in key, or an empty string for the keys
+// written before photos had one, whose content may have been replaced.
+func PhotoVersion(key string) string {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != "employee_pic" {
+		return ""
+	}
+	return parts[2]
+}
+
+// IsPhotoKey tells whether key names a rendition of a versioned photo, the
+// only objects the photo route serves.
+func IsPhotoKey(key string) bool {
+	parts := strings.Split(key, "/")
+	if PhotoVersion(key) == "" || parts[1] == "" {
+		return false
+	}
+	for _, name := range []string{RenditionThumbnail, RenditionProfile, RenditionOriginal} {
+		if parts[3] == renditionFile(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func renditionFile(rendition string) string {
+	if rendition == RenditionOriginal {
+		return rendition
+	}
+	return rendition + ".png"
+}
+
+// HasRenditions tells whether the photo was uploaded with its renditions.
+// Photos from before them are a single object, used for every rendition.
+func (p Photo) HasRenditions() bool {
+	return strings.HasSuffix(p.ObjectKey, "/"+renditionFile(RenditionProfile))
+}
+
+// RenditionKey returns the object key of the rendition called name.
+func (p Photo) RenditionKey(name string) string {
+	if !p.HasRenditions() {
+		return p.ObjectKey
+	}
+	return path.Dir(p.ObjectKey) + "/" + renditionFile(name)
+}
+
+// URL returns the signed url of the rendition called name.
+func (p Photo) URL(name string) string {
+	if url, exist := p.Renditions[name]; exist {
+		return url
+	}
+	return p.SignedUrl
+}
+
+// Srcset lists the scaled renditions with their widths, for the srcset
+// attribute of an img, or is empty when the photo has a single object.
+func (p Photo) Srcset() string {
+	if !p.HasRenditions() {
+		return ""
+	}
+	sources := make([]string, 0, len(PhotoRenditions))
+	for _, r := range PhotoRenditions {
+		sources = append(sources, fmt.Sprintf("%s %dw", p.URL(r.Name), r.Width))
+	}
+	return strings.Join(sources, ", ")
+}
+
+// CropBox accepts the crop of the edit form, "x,y,width,height" in pixels
+// of the uploaded picture.
+func CropBox() Rule {
+	return func(value string) error {
+		if _, ok := parseCrop(value); !ok {
+			return fmt.Errorf("must be a box such as 10,20,300,400")
+		}
+		return nil
+	}
+}
+
+func parseCrop(value string) (image.Rectangle, bool) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, false
+	}
+
+	var n [4]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || v < 0 {
+			return image.Rectangle{}, false
+		}
+		n[i] = v
+	}
+	if n[2] == 0 || n[3] == 0 {
+		return image.Rectangle{}, false
+	}
+
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), true
+}
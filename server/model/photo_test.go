@@ -0,0 +1,124 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"mime/multipart"
+	"strings"
+	"testing"
+)
+
+// upload returns content as the file of a submitted form.
+func upload(t *testing.T, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("photo", "photo.png")
+	part.Write(content)
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm() = %v", err)
+	}
+	return form.File["photo"][0]
+}
+
+func TestValidatePhotoChecksTheSizeFirst(t *testing.T) {
+	// a PNG signature and header claiming 20000x20000 pixels, with none
+	// behind them: decoding it whole would fail, so only a size error
+	// shows that the header alone was read
+	chunk := make([]byte, 4+13)
+	copy(chunk, "IHDR")
+	binary.BigEndian.PutUint32(chunk[4:], 20000)
+	binary.BigEndian.PutUint32(chunk[8:], 20000)
+	chunk[12], chunk[13] = 8, 2
+	file := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	file = append(file, chunk...)
+	file = binary.BigEndian.AppendUint32(file, crc32.ChecksumIEEE(chunk))
+
+	form := NewForm()
+	form.SetPhotoLimits(DefaultPhotoLimits)
+	form.validatePhoto(upload(t, file))
+
+	if len(form.Photo.Errors) != 1 || !strings.Contains(form.Photo.Errors[0], "at most 6000x6000 pixels, got 20000x20000") {
+		t.Errorf("validatePhoto() errors = %q, want the picture refused for its size", form.Photo.Errors)
+	}
+	if form.Photo.Data != nil {
+		t.Errorf("validatePhoto() kept the picture")
+	}
+}
+
+func TestValidatePhotoRejectsMarkup(t *testing.T) {
+	for name, file := range map[string]string{
+		"svg":  `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`,
+		"html": `<!DOCTYPE html><script>alert(1)</script>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			form := NewForm()
+			form.SetPhotoLimits(DefaultPhotoLimits)
+			form.validatePhoto(upload(t, []byte(file)))
+
+			if len(form.Photo.Errors) != 1 || !strings.Contains(form.Photo.Errors[0], "must be a JPEG, PNG or GIF image") {
+				t.Errorf("validatePhoto() errors = %q, want the file refused as no picture", form.Photo.Errors)
+			}
+		})
+	}
+}
+
+func TestIsPhotoKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{PhotoKey("42", "df73945ec73f3001", RenditionProfile), true},
+		{PhotoKey("42", "df73945ec73f3001", RenditionThumbnail), true},
+		{PhotoKey("42", "df73945ec73f3001", RenditionOriginal), true},
+		{"employee_pic/42.jpg", false},
+		{"employee_pic/42/df73945ec73f3001/secrets.txt", false},
+		{"employee_pic//df73945ec73f3001/profile.png", false},
+		{"employee_pic/42//profile.png", false},
+		{"backups/42/df73945ec73f3001/profile.png", false},
+		{"db-dump.sql", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := IsPhotoKey(tt.key); got != tt.want {
+				t.Errorf("IsPhotoKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovesPhoto(t *testing.T) {
+	tests := []struct {
+		name     string
+		remove   bool
+		newPhoto bool
+		want     bool
+	}{
+		{"kept", false, false, false},
+		{"removed", true, false, true},
+		{"replaced", true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := NewForm()
+			if tt.newPhoto {
+				form.Photo.Data = []byte("picture")
+			}
+			submitted := &multipart.Form{Value: map[string][]string{}}
+			if tt.remove {
+				submitted.Value[form.RemovePhoto.Name] = []string{"on"}
+			}
+			form.ValidateOnSubmit(submitted)
+
+			if got := form.RemovesPhoto(); got != tt.want {
+				t.Errorf("RemovesPhoto() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// Kinds of team. A department is usually larger and has teams inside it,
+// but both are stored and shown the same way. The departments an employee
+// is a member of are also shown as their department, see Departments.
+const (
+	TeamKindDepartment = "department"
+	TeamKindTeam       = "team"
+)
+
+// TeamKinds lists the kinds of team, with the name shown on the pages.
+var TeamKinds = map[string]string{
+	TeamKindDepartment: "Department",
+	TeamKindTeam:       "Team",
+}
+
+// Team is a department or team employees belong to. An employee can be a
+// member of several of them, through Profile.Teams.
+type Team struct {
+	Id          string `dynamodbav:"-"`
+	Name        string `dynamodbav:"name"`
+	Kind        string `dynamodbav:"kind"`
+	Description string `dynamodbav:"description,omitempty"`
+	LeadId      string `dynamodbav:"lead_id,omitempty"`
+}
+
+// KindName returns the kind as pages show it.
+func (t Team) KindName() string {
+	return TeamKinds[t.Kind]
+}
+
+// Departments returns the names of the departments among the teams with
+// the given ids, sorted and separated by commas, or "" when there is none.
+func Departments(teams []*Team, teamIds []string) string {
+	var names []string
+	for _, t := range teams {
+		if t.Kind == TeamKindDepartment && containsString(teamIds, t.Id) {
+			names = append(names, t.Name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// DescriptionLines splits the description at its line breaks, so pages can
+// show them.
+func (t Team) DescriptionLines() []string {
+	return strings.Split(t.Description, "\n")
+}
+
+// SortTeams orders teams by name, as lists and selectors show them.
+func SortTeams(teams []*Team) {
+	sort.SliceStable(teams, func(i, j int) bool {
+		return teams[i].Name < teams[j].Name
+	})
+}
+
+type TeamForm struct {
+	TeamId      Field
+	Name        Field
+	Kind        Field
+	Description Field
+	Lead        Field
+}
+
+func NewTeamForm() TeamForm {
+	kinds := make([]string, 0, len(TeamKinds))
+	for kind := range TeamKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	return TeamForm{
+		TeamId:      Field{IsRequired: false, Name: "team_id", Label: "Team Id", Rules: []Rule{MaxLength(64), Identifier()}},
+		Name:        Field{IsRequired: true, Name: "name", Label: "Name", Rules: []Rule{MaxLength(MaxTextLength), Printable()}},
+		Kind:        Field{IsRequired: true, Name: "kind", Label: "Kind", Data: TeamKindTeam, Rules: []Rule{OneOf(kinds)}},
+		Description: Field{IsRequired: false, Name: "description", Label: "Description", Rules: []Rule{MaxLength(2000), MultilineText()}},
+		Lead:        Field{IsRequired: false, Name: "lead_id", Label: "Lead", Rules: []Rule{MaxLength(64), Identifier()}},
+	}
+}
+
+// NewTeamEditForm returns an empty form filled with the values of team, for
+// editing.
+func NewTeamEditForm(team *Team) TeamForm {
+	f := NewTeamForm()
+	f.TeamId.Data = team.Id
+	f.Name.Data = team.Name
+	f.Kind.Data = team.Kind
+	f.Description.Data = team.Description
+	f.Lead.Data = team.LeadId
+	return f
+}
+
+// ValidateOnSubmit checks every field of the submitted values, as
+// Form.ValidateOnSubmit does.
+func (f *TeamForm) ValidateOnSubmit(values map[string][]string) error {
+	f.TeamId.validateText(values[f.TeamId.Name])
+	f.Name.validateText(values[f.Name.Name])
+	f.Kind.validateText(values[f.Kind.Name])
+	f.Description.validateMultiline(values[f.Description.Name])
+	f.Lead.validateText(values[f.Lead.Name])
+
+	return f.Err()
+}
+
+// Team returns the team of a validated form.
+func (f TeamForm) Team() Team {
+	return Team{
+		Id:          f.TeamId.ToString(),
+		Name:        f.Name.ToString(),
+		Kind:        f.Kind.ToString(),
+		Description: f.Description.ToString(),
+		LeadId:      f.Lead.ToString(),
+	}
+}
+
+// Err returns the problems recorded on the fields, or nil when there is
+// none.
+func (f TeamForm) Err() error {
+	return fieldsErr([]Field{f.TeamId, f.Name, f.Kind, f.Description, f.Lead})
+}
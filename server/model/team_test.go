@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+func TestDepartments(t *testing.T) {
+	teams := []*Team{
+		{Id: "1", Name: "Sales", Kind: TeamKindDepartment},
+		{Id: "2", Name: "Engineering", Kind: TeamKindDepartment},
+		{Id: "3", Name: "Platform", Kind: TeamKindTeam},
+	}
+
+	tests := []struct {
+		name    string
+		teamIds []string
+		want    string
+	}{
+		{"no teams", nil, ""},
+		{"only plain teams", []string{"3"}, ""},
+		{"one department", []string{"3", "1"}, "Sales"},
+		{"departments sorted", []string{"1", "2"}, "Engineering, Sales"},
+		{"unknown team", []string{"42"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Departments(teams, tt.teamIds); got != tt.want {
+				t.Errorf("Departments(%q) = %q, want %q", tt.teamIds, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package model
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTextLength matches the nvarchar(200) columns of the MySQL schema.
+const MaxTextLength = 200
+
+var decimal = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// DateLayout is the format of dates, such as the start date, both in the
+// forms and in the stores.
+const DateLayout = "2006-01-02"
+
+// Rule checks a normalized, non-empty value and describes what is wrong
+// with it, so several rules can report on the same field at once.
+type Rule func(value string) error
+
+// MaxLength limits the number of characters, not bytes, as the database
+// columns do.
+func MaxLength(n int) Rule {
+	return func(value string) error {
+		if count := utf8.RuneCountInString(value); count > n {
+			return fmt.Errorf("must have at most %d characters, got %d", n, count)
+		}
+		return nil
+	}
+}
+
+// Allowed accepts values made only of the characters for which allowed
+// returns true; description tells the user which ones they are.
+func Allowed(description string, allowed func(r rune) bool) Rule {
+	return func(value string) error {
+		var invalid []string
+		for _, r := range value {
+			if !allowed(r) && !containsRune(invalid, r) {
+				invalid = append(invalid, string(r))
+			}
+		}
+		if len(invalid) > 0 {
+			return fmt.Errorf("may only contain %s, found %q", description, strings.Join(invalid, ""))
+		}
+		return nil
+	}
+}
+
+// Printable rejects control and invisible formatting characters, which have
+// no place in a directory entry and can be used to spoof names.
+func Printable() Rule {
+	return Allowed("printable characters", func(r rune) bool {
+		return unicode.IsPrint(r)
+	})
+}
+
+// PersonName accepts letters in any script, combining marks, spaces and the
+// punctuation found in names such as "Mary-Jane O'Neil Jr.". Digits are
+// accepted too, for suffixes such as "John Smith 2nd" and for names that
+// legally carry them; the rule is only meant to keep symbols out.
+func PersonName() Rule {
+	return Allowed("letters, digits, spaces, apostrophes, hyphens, periods and commas", func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r) || r == ' ' || strings.ContainsRune("'’-.,", r)
+	})
+}
+
+// Identifier accepts the ids generated by the stores: numbers, uuids and
+// other ASCII tokens.
+func Identifier() Rule {
+	return Allowed("letters, digits, '-' and '_'", func(r rune) bool {
+		return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_')
+	})
+}
+
+// Email accepts a bare address such as "jane@example.com", without a display
+// name or angle brackets.
+func Email() Rule {
+	return func(value string) error {
+		addr, err := mail.ParseAddress(value)
+		if err != nil || addr.Address != value || !strings.Contains(value[strings.LastIndex(value, "@"):], ".") {
+			return fmt.Errorf("must be an email address such as jane@example.com")
+		}
+		return nil
+	}
+}
+
+// PhoneNumber accepts numbers written as they are dialed from abroad or
+// locally, e.g. "+1 (206) 555-0100", with between 7 and 15 digits, the
+// latter being the longest number E.164 allows.
+func PhoneNumber() Rule {
+	return func(value string) error {
+		digits := 0
+		for i, r := range value {
+			switch {
+			case r >= '0' && r <= '9':
+				digits++
+			case r == '+' && i == 0:
+			case strings.ContainsRune(" -().", r):
+			default:
+				return fmt.Errorf("must be a phone number such as +1 206 555 0100, found %q", string(r))
+			}
+		}
+		if digits < 7 || digits > 15 {
+			return fmt.Errorf("must have between 7 and 15 digits, got %d", digits)
+		}
+		return nil
+	}
+}
+
+// Date accepts calendar dates in the DateLayout format, as sent by date
+// inputs.
+func Date() Rule {
+	return func(value string) error {
+		if _, err := time.Parse(DateLayout, value); err != nil {
+			return fmt.Errorf("must be a date such as 2006-01-02")
+		}
+		return nil
+	}
+}
+
+// Timezone accepts the names of the IANA time zone database, such as
+// "America/Sao_Paulo".
+func Timezone() Rule {
+	return func(value string) error {
+		if value == "Local" {
+			return fmt.Errorf("must be a time zone such as America/Sao_Paulo")
+		}
+		if _, err := time.LoadLocation(value); err != nil {
+			return fmt.Errorf("must be a time zone such as America/Sao_Paulo")
+		}
+		return nil
+	}
+}
+
+// Handle accepts user names as the chat and social networks print them,
+// once the form has removed the leading "@".
+func Handle() Rule {
+	return Allowed("letters, digits, '.', '-' and '_'", func(r rune) bool {
+		return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-", r))
+	})
+}
+
+// MultilineText rejects the same characters as Printable but keeps line
+// breaks, e.g. for the bio.
+func MultilineText() Rule {
+	return Allowed("printable characters", func(r rune) bool {
+		return unicode.IsPrint(r) || r == '\n'
+	})
+}
+
+// Number accepts integers and decimals such as "42" or "-3.5".
+func Number() Rule {
+	return func(value string) error {
+		if !decimal.MatchString(value) {
+			return fmt.Errorf("must be a number such as 42 or 3.5")
+		}
+		return nil
+	}
+}
+
+// Between accepts numbers from min to max. Values that are not numbers are
+// left to the Number rule.
+func Between(min, max float64) Rule {
+	return func(value string) error {
+		n, err := strconv.ParseFloat(value, 64)
+		if err == nil && (n < min || n > max) {
+			return fmt.Errorf("must be between %g and %g", min, max)
+		}
+		return nil
+	}
+}
+
+// URL accepts absolute http and https addresses.
+func URL() Rule {
+	return func(value string) error {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("must be an address such as https://example.com")
+		}
+		return nil
+	}
+}
+
+// OneOf accepts only the given options.
+func OneOf(options []string) Rule {
+	return func(value string) error {
+		if !containsString(options, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+		}
+		return nil
+	}
+}
+
+// Normalize puts text in NFC form, so that "é" typed as one or as two code
+// points is stored and searched the same way, and collapses any run of
+// Unicode whitespace into a single space.
+func Normalize(value string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(value)), " ")
+}
+
+// normalizeLines normalizes each line of a multiline value on its own, so
+// the line breaks survive, and drops the blank lines around it.
+func normalizeLines(value string) string {
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = Normalize(line)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func containsRune(list []string, r rune) bool {
+	return containsString(list, string(r))
+}
+
+func containsString(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package model
+
+import "testing"
+
+func TestMaxLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		max   int
+		value string
+		valid bool
+	}{
+		{"ascii at the limit", 5, "abcde", true},
+		{"ascii over the limit", 5, "abcdef", false},
+		{"accents count once", 5, "João!", true},
+		{"cjk counts per character", 3, "山田太", true},
+		{"emoji counts per character", 2, "😀😀", true},
+		{"multibyte over the limit", 2, "😀😀😀", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MaxLength(tt.max)(tt.value)
+			if (err == nil) != tt.valid {
+				t.Errorf("MaxLength(%d)(%q) = %v, want valid %v", tt.max, tt.value, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestPersonName(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"Mary-Jane O'Neil Jr.", true},
+		{"John Smith 2nd", true},
+		{"Smith, John", true},
+		{"José Ñúñez", true},
+		{"Zoë D’Arcy", true},
+		{"山田 太郎", true},
+		{"Ana<script>", false},
+		{"ann@example.com", false},
+		{"Bob_Builder", false},
+		{"Ann\u202eevil", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			err := PersonName()(tt.value)
+			if (err == nil) != tt.valid {
+				t.Errorf("PersonName()(%q) = %v, want valid %v", tt.value, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"42", true},
+		{"3f2b8a1e-6c1d-4e2b-9a7f-0c5d2e8b1a64", true},
+		{"team_lead", true},
+		{"schema#custom_fields", false},
+		{"a b", false},
+		{"José", false},
+		{"../etc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			err := Identifier()(tt.value)
+			if (err == nil) != tt.valid {
+				t.Errorf("Identifier()(%q) = %v, want valid %v", tt.value, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"decomposed accent is composed", "Jose\u0301", "José"},
+		{"composed accent is kept", "José", "José"},
+		{"spaces around are trimmed", "  Ann  ", "Ann"},
+		{"runs of spaces collapse", "Ann   Lee", "Ann Lee"},
+		{"tabs and newlines collapse", "Ann\t\n Lee", "Ann Lee"},
+		{"unicode spaces collapse", "Ann\u00a0\u2003Lee", "Ann Lee"},
+		{"blank becomes empty", " \t ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.value); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type originKey struct{}
+
+// Origin is where a request was addressed to as the client sees it, which
+// differs from the connection when a load balancer terminates TLS or mounts
+// the application under a path prefix.
+type Origin struct {
+	Scheme string
+	Host   string
+	Prefix string
+}
+
+// URL returns the absolute url of path, e.g. /employee/1, as seen by the
+// client.
+func (o Origin) URL(path string) string {
+	return o.Scheme + "://" + o.Host + o.Prefix + path
+}
+
+// FromContext returns the origin stored by Forwarded.
+func FromContext(ctx context.Context) Origin {
+	if origin, ok := ctx.Value(originKey{}).(Origin); ok {
+		return origin
+	}
+	return Origin{Scheme: "http"}
+}
+
+// Forwarded resolves the origin of each request. X-Forwarded-Proto, -Host
+// and -Prefix are only honored from the trusted networks, as anyone could
+// send them otherwise; a base url, when given, takes precedence over both
+// the headers and the connection.
+func Forwarded(trusted []*net.IPNet, base *url.URL) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := Origin{Scheme: "http", Host: r.Host}
+			if r.TLS != nil {
+				origin.Scheme = "https"
+			}
+
+			if base != nil {
+				origin = Origin{Scheme: base.Scheme, Host: base.Host, Prefix: cleanPrefix(base.Path)}
+			} else if isTrusted(trusted, r.RemoteAddr) {
+				if proto := strings.ToLower(first(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+					origin.Scheme = proto
+				}
+				if host := first(r.Header.Get("X-Forwarded-Host")); host != "" {
+					origin.Host = host
+				}
+				origin.Prefix = cleanPrefix(first(r.Header.Get("X-Forwarded-Prefix")))
+			}
+
+			ctx := context.WithValue(r.Context(), originKey{}, origin)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func isTrusted(trusted []*net.IPNet, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// first returns the value added by the proxy closest to the client when a
+// header went through more than one of them.
+func first(value string) string {
+	value, _, _ = strings.Cut(value, ",")
+	return strings.TrimSpace(value)
+}
+
+// cleanPrefix turns "staff/", "/staff" or "/staff/" into "/staff", and an
+// empty or root prefix into "".
+func cleanPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	prefix = path.Clean("/" + prefix)
+	if prefix == "/" {
+		return ""
+	}
+	return prefix
+}
@@ -0,0 +1,68 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/moura1001/aws-employee-directory-application/server/proxy"
+)
+
+type nonceKey struct{}
+
+// contentSecurityPolicy only allows scripts, styles and fonts served by the
+// application itself. Images may also come from https, where the presigned
+// S3 urls point, and from data: urls used by the Bootstrap stylesheet.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'nonce-%s'; " +
+	"style-src 'self'; " +
+	"img-src 'self' data: https:; " +
+	"font-src 'self'; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// NonceFromContext returns the nonce that inline scripts of the current
+// response must carry in their nonce attribute.
+func NonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// Headers sets the security headers of every response, with a fresh CSP
+// nonce per request. HSTS is only sent when the client connected over TLS,
+// to the application or to the load balancer, as browsers ignore it over
+// plain HTTP.
+func Headers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := newNonce()
+		if err != nil {
+			http.Error(w, "error to generate csp nonce", http.StatusInternalServerError)
+			return
+		}
+
+		header := w.Header()
+		header.Set("Content-Security-Policy", fmt.Sprintf(contentSecurityPolicy, nonce))
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		header.Set("Cross-Origin-Opener-Policy", "same-origin")
+		if proxy.FromContext(r.Context()).Scheme == "https" {
+			header.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		}
+
+		ctx := context.WithValue(r.Context(), nonceKey{}, nonce)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -2,47 +2,319 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
 	"github.com/moura1001/aws-employee-directory-application/server/model"
-	"github.com/moura1001/aws-employee-directory-application/server/utils"
 )
 
+const (
+	dynamoLocationIndex = "location-index"
+	dynamoJobTitleIndex = "job_title-index"
+	dynamoBadgeIndex    = "badge-index"
+	dynamoManagerIndex  = "manager-index"
+	dynamoTeamIndex     = "team-index"
+	dynamoItemTypeIndex = "item_type-index"
+
+	dynamoBadgeItemType      = "badge"
+	dynamoTeamItemType       = "team"
+	dynamoTeamMemberItemType = "team_member"
+	dynamoOfficeItemType     = "office"
+
+	// the custom field definitions are kept together in a single item, so
+	// forms read them with one GetItem
+	dynamoSchemaItemType   = "schema"
+	dynamoCustomFieldsItem = "schema#custom_fields"
+	dynamoMigrationsItem   = "schema#migrations"
+
+	// dynamoEmployeeCondition keeps a write to an employee from creating it
+	// or from changing one of the other items of the table instead
+	dynamoEmployeeCondition = "attribute_exists(id) AND attribute_not_exists(item_type)"
+
+	// dynamoBadgeItemsMigration is the version of the table once the badge
+	// items of employees written before them were backfilled
+	dynamoBadgeItemsMigration = 1
+)
+
+func init() {
+	RegisterEmployeeStore("dynamodb", func(dsn *url.URL) (EmployeeStore, error) {
+		table := dsn.Host
+		if table == "" {
+			table = "Employees"
+		}
+
+		store := NewDynamoStore(table, dsn.Query().Get("region"))
+
+		if segments := dsn.Query().Get("segments"); segments != "" {
+			n, err := strconv.ParseInt(segments, 10, 32)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("dynamodb store 'segments' must be a positive number, got '%s'", segments)
+			}
+			store.scanSegments = int32(n)
+		}
+
+		return store, nil
+	})
+}
+
 type DynamoStore struct {
-	table string
+	table        string
+	region       string
+	scanSegments int32
+
+	mu       sync.Mutex
+	migrated atomic.Bool
+}
+
+// badgeItem is an adjacency item stored in the employees table for each
+// badge an employee holds. Only these items carry the "badge" attribute, so
+// the badge index is sparse and maps a badge to the ids of its holders.
+type badgeItem struct {
+	Id         string `dynamodbav:"id"`
+	ItemType   string `dynamodbav:"item_type"`
+	Badge      string `dynamodbav:"badge"`
+	EmployeeId string `dynamodbav:"employee_id"`
+}
+
+// teamItem is a department or team, listed through the item type index.
+// Its id is the team id prefixed with "team#".
+type teamItem struct {
+	Id       string `dynamodbav:"id"`
+	ItemType string `dynamodbav:"item_type"`
+	model.Team
+}
+
+// officeItem is an office, listed through the item type index like the
+// teams. Its id is the office id prefixed with "office#".
+type officeItem struct {
+	Id       string `dynamodbav:"id"`
+	ItemType string `dynamodbav:"item_type"`
+	model.Office
+}
+
+// teamMemberItem is an adjacency item like badgeItem, one per team an
+// employee is a member of, read through the team index.
+type teamMemberItem struct {
+	Id         string `dynamodbav:"id"`
+	ItemType   string `dynamodbav:"item_type"`
+	TeamId     string `dynamodbav:"team_id"`
+	EmployeeId string `dynamodbav:"employee_id"`
+}
+
+// customFieldsItem holds every custom field definition. Version guards the
+// read-modify-write of SaveCustomField and DeleteCustomField against
+// concurrent changes.
+type customFieldsItem struct {
+	Id       string              `dynamodbav:"id"`
+	ItemType string              `dynamodbav:"item_type"`
+	Fields   []model.CustomField `dynamodbav:"fields"`
+	Version  int                 `dynamodbav:"version"`
+}
+
+// migrationsItem records the data migrations that ran on the table, so
+// each runs once.
+type migrationsItem struct {
+	Id       string `dynamodbav:"id"`
+	ItemType string `dynamodbav:"item_type"`
+	Version  int    `dynamodbav:"version"`
 }
 
-func NewDynamoStore() *DynamoStore {
+func NewDynamoStore(table, region string) *DynamoStore {
 	return &DynamoStore{
-		table: "Employees",
+		table:        table,
+		region:       region,
+		scanSegments: 4,
+	}
+}
+
+func newBadgeItem(employeeId, badge string) badgeItem {
+	return badgeItem{
+		Id:         employeeId + "#" + dynamoBadgeItemType + "#" + badge,
+		ItemType:   dynamoBadgeItemType,
+		Badge:      badge,
+		EmployeeId: employeeId,
+	}
+}
+
+func newTeamItem(team model.Team) teamItem {
+	return teamItem{
+		Id:       dynamoTeamItemType + "#" + team.Id,
+		ItemType: dynamoTeamItemType,
+		Team:     team,
+	}
+}
+
+func (t teamItem) team() *model.Team {
+	team := t.Team
+	team.Id = strings.TrimPrefix(t.Id, dynamoTeamItemType+"#")
+	return &team
+}
+
+func newOfficeItem(office model.Office) officeItem {
+	return officeItem{
+		Id:       dynamoOfficeItemType + "#" + office.Id,
+		ItemType: dynamoOfficeItemType,
+		Office:   office,
+	}
+}
+
+func (o officeItem) office() *model.Office {
+	office := o.Office
+	office.Id = strings.TrimPrefix(o.Id, dynamoOfficeItemType+"#")
+	return &office
+}
+
+func newTeamMemberItem(employeeId, teamId string) teamMemberItem {
+	return teamMemberItem{
+		Id:         employeeId + "#" + dynamoTeamItemType + "#" + teamId,
+		ItemType:   dynamoTeamMemberItemType,
+		TeamId:     teamId,
+		EmployeeId: employeeId,
 	}
 }
 
 func (db *DynamoStore) ListEmployees() ([]*model.Employee, error) {
-	errMsg := "error to get employee list%s. Details: '%s'"
+	filter := expression.AttributeNotExists(expression.Name("item_type"))
+	return db.scanEmployees("", filter)
+}
 
-	var emps []*model.Employee
+// scanEmployees reads the whole table in parallel segments, keeping the
+// items that match filter.
+func (db *DynamoStore) scanEmployees(by string, filter expression.ConditionBuilder) ([]*model.Employee, error) {
+	errMsg := "error to get employee list" + by + "%s. Details: '%s'"
 
 	svc, err := db.getDynamoClient()
 	if err != nil {
 		return nil, fmt.Errorf(errMsg, "", err)
 	}
 
-	empData, err := svc.Scan(context.TODO(), &dynamodb.ScanInput{
-		TableName: aws.String(db.table),
-	})
+	expr, _ := expression.NewBuilder().WithFilter(filter).Build()
+
+	var (
+		wg       sync.WaitGroup
+		segments = make([][]map[string]types.AttributeValue, db.scanSegments)
+		errs     = make([]error, db.scanSegments)
+	)
+
+	for segment := int32(0); segment < db.scanSegments; segment++ {
+		wg.Add(1)
+		go func(segment int32) {
+			defer wg.Done()
+
+			paginator := dynamodb.NewScanPaginator(svc, &dynamodb.ScanInput{
+				TableName:                 aws.String(db.table),
+				FilterExpression:          expr.Filter(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				Segment:                   aws.Int32(segment),
+				TotalSegments:             aws.Int32(db.scanSegments),
+			})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(context.TODO())
+				if err != nil {
+					errs[segment] = err
+					return
+				}
+				segments[segment] = append(segments[segment], page.Items...)
+			}
+		}(segment)
+	}
+	wg.Wait()
+
+	var items []map[string]types.AttributeValue
+	for segment := range segments {
+		if errs[segment] != nil {
+			return nil, fmt.Errorf(errMsg, " Scan", errs[segment])
+		}
+		items = append(items, segments[segment]...)
+	}
+
+	emps := []*model.Employee{}
+	err = attributevalue.UnmarshalListOfMaps(items, &emps)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+	}
+
+	return emps, nil
+}
+
+func (db *DynamoStore) ListEmployeesByLocation(location string) ([]*model.Employee, error) {
+	return db.queryEmployees(dynamoLocationIndex, "location", location)
+}
+
+func (db *DynamoStore) ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error) {
+	return db.queryEmployees(dynamoJobTitleIndex, "job_title", jobTitle)
+}
+
+func (db *DynamoStore) ListEmployeesByBadge(badge string) ([]*model.Employee, error) {
+	if !db.migrated.Load() {
+		// employees saved before the badge index may have no badge items
+		// yet, so until Migrate has written them the badges are scanned
+		filter := expression.AttributeNotExists(expression.Name("item_type")).
+			And(expression.Contains(expression.Name("badges"), badge))
+		return db.scanEmployees(" by badge", filter)
+	}
+	return db.queryMembers(dynamoBadgeIndex, "badge", badge)
+}
+
+func (db *DynamoStore) ListEmployeesByTeam(teamId string) ([]*model.Employee, error) {
+	return db.queryMembers(dynamoTeamIndex, "team_id", teamId)
+}
+
+func (db *DynamoStore) ListEmployeesByManager(managerId string) ([]*model.Employee, error) {
+	return db.queryEmployees(dynamoManagerIndex, "manager_id", managerId)
+}
+
+// queryMembers finds the adjacency items of a badge or team in their index,
+// then reads the employees they point to.
+func (db *DynamoStore) queryMembers(index, attribute, value string) ([]*model.Employee, error) {
+	errMsg := "error to get employee list by " + attribute + "%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	items, err := db.queryIndex(svc, index, attribute, value)
 	if err != nil {
 		return nil, fmt.Errorf(errMsg, " Query", err)
 	}
 
-	err = attributevalue.UnmarshalListOfMaps(empData.Items, &emps)
+	var members []struct {
+		EmployeeId string `dynamodbav:"employee_id"`
+	}
+	err = attributevalue.UnmarshalListOfMaps(items, &members)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+	}
+
+	keys := make([]map[string]types.AttributeValue, 0, len(members))
+	for _, item := range members {
+		key, _ := attributevalue.MarshalMap(map[string]string{
+			"id": item.EmployeeId,
+		})
+		keys = append(keys, key)
+	}
+
+	items, err = db.batchGetItems(svc, keys)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " BatchGetItem", err)
+	}
+
+	emps := []*model.Employee{}
+	err = attributevalue.UnmarshalListOfMaps(items, &emps)
 	if err != nil {
 		return nil, fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
 	}
@@ -50,11 +322,134 @@ func (db *DynamoStore) ListEmployees() ([]*model.Employee, error) {
 	return emps, nil
 }
 
+// ListSubordinates walks the hierarchy level by level with one query of the
+// manager index per manager, as DynamoDB has no recursive queries.
+func (db *DynamoStore) ListSubordinates(employeeId string) ([]*model.Employee, error) {
+	errMsg := "error to get subordinates%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	res := []*model.Employee{}
+	found := map[string]bool{employeeId: true}
+	for frontier := []string{employeeId}; len(frontier) > 0; {
+		var next []string
+		for _, managerId := range frontier {
+			items, err := db.queryIndex(svc, dynamoManagerIndex, "manager_id", managerId)
+			if err != nil {
+				return nil, fmt.Errorf(errMsg, " Query", err)
+			}
+
+			var reports []*model.Employee
+			err = attributevalue.UnmarshalListOfMaps(items, &reports)
+			if err != nil {
+				return nil, fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+			}
+
+			for _, e := range reports {
+				if !found[e.Id] {
+					found[e.Id] = true
+					res = append(res, e)
+					next = append(next, e.Id)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return res, nil
+}
+
+func (db *DynamoStore) queryEmployees(index, attribute, value string) ([]*model.Employee, error) {
+	errMsg := "error to get employee list by " + attribute + "%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	items, err := db.queryIndex(svc, index, attribute, value)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " Query", err)
+	}
+
+	emps := []*model.Employee{}
+	err = attributevalue.UnmarshalListOfMaps(items, &emps)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+	}
+
+	return emps, nil
+}
+
+func (db *DynamoStore) queryIndex(svc *dynamodb.Client, index, attribute, value string) ([]map[string]types.AttributeValue, error) {
+	keyCond := expression.Key(attribute).Equal(expression.Value(value))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []map[string]types.AttributeValue
+
+	paginator := dynamodb.NewQueryPaginator(svc, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.table),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+
+	return items, nil
+}
+
+func (db *DynamoStore) batchGetItems(svc *dynamodb.Client, keys []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	// BatchGetItem accepts at most 100 keys per request
+	const batchSize = 100
+
+	var items []map[string]types.AttributeValue
+
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		requestItems := map[string]types.KeysAndAttributes{
+			db.table: {Keys: keys[start:end]},
+		}
+		for len(requestItems) > 0 {
+			out, err := svc.BatchGetItem(context.TODO(), &dynamodb.BatchGetItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, out.Responses[db.table]...)
+			requestItems = out.UnprocessedKeys
+		}
+	}
+
+	return items, nil
+}
+
 func (db *DynamoStore) LoadEmployee(employeeId string) (*model.Employee, error) {
 	errMsg := "error to get employee data%s. Details: '%s'"
 
 	emp := &model.Employee{Photo: new(model.Photo)}
 
+	if !isRecordId(employeeId) {
+		return nil, ErrNotFound
+	}
+
 	svc, err := db.getDynamoClient()
 	if err != nil {
 		return nil, fmt.Errorf(errMsg, "", err)
@@ -73,8 +468,9 @@ func (db *DynamoStore) LoadEmployee(employeeId string) (*model.Employee, error)
 		return nil, fmt.Errorf(errMsg, " GetItem", err)
 	}
 
-	if empItem.Item == nil {
-		return nil, fmt.Errorf(errMsg, "", "data not found")
+	// the schema, team and office items share the table with employees
+	if _, typed := empItem.Item["item_type"]; empItem.Item == nil || typed {
+		return nil, ErrNotFound
 	}
 
 	err = attributevalue.UnmarshalMap(empItem.Item, emp)
@@ -85,7 +481,7 @@ func (db *DynamoStore) LoadEmployee(employeeId string) (*model.Employee, error)
 	return emp, nil
 }
 
-func (db *DynamoStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string) (string, error) {
+func (db *DynamoStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error) {
 	errMsg := "error to insert employee data%s. Details: '%s'"
 
 	svc, err := db.getDynamoClient()
@@ -102,6 +498,7 @@ func (db *DynamoStore) AddEmployee(objectKey, fullName, location, jobTitle strin
 		Location: location,
 		JobTitle: jobTitle,
 		Badges:   badges,
+		Profile:  profile,
 	}
 
 	empItem, err := attributevalue.MarshalMap(emp)
@@ -109,18 +506,43 @@ func (db *DynamoStore) AddEmployee(objectKey, fullName, location, jobTitle strin
 		return "", fmt.Errorf(errMsg, " MarshalMap", err)
 	}
 
-	_, err = svc.PutItem(context.TODO(), &dynamodb.PutItemInput{
-		TableName: aws.String(db.table),
-		Item:      empItem,
+	items := []types.TransactWriteItem{
+		{Put: &types.Put{
+			TableName: aws.String(db.table),
+			Item:      empItem,
+		}},
+	}
+
+	badgeWrites, err := db.putBadgeItems(emp.Id, badges)
+	if err != nil {
+		return "", fmt.Errorf(errMsg, " MarshalMap", err)
+	}
+	items = append(items, badgeWrites...)
+
+	teamWrites, err := db.putTeamMemberItems(emp.Id, profile.Teams)
+	if err != nil {
+		return "", fmt.Errorf(errMsg, " MarshalMap", err)
+	}
+	items = append(items, teamWrites...)
+
+	_, err = svc.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
 	})
 	if err != nil {
-		return "", fmt.Errorf(errMsg, " PutItem", err)
+		return "", fmt.Errorf(errMsg, " TransactWriteItems", err)
 	}
 
 	return emp.Id, nil
 }
 
-func (db *DynamoStore) UpdateEmployee(employeeId, objectKey, fullName, location, jobTitle string, badges []string) error {
+// isRecordId tells the ids of employees, teams and offices, which are
+// numbers or uuids, from the keys of the items of the table, which all but
+// employees have with a '#'.
+func isRecordId(id string) bool {
+	return id != "" && !strings.Contains(id, "#")
+}
+
+func (db *DynamoStore) UpdateEmployee(employeeId, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error {
 	errMsg := "error to update employee data%s. Details: '%s'"
 
 	svc, err := db.getDynamoClient()
@@ -128,6 +550,11 @@ func (db *DynamoStore) UpdateEmployee(employeeId, objectKey, fullName, location,
 		return fmt.Errorf(errMsg, "", err)
 	}
 
+	current, err := db.LoadEmployee(employeeId)
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
 	selectedKeys := map[string]string{
 		"id": employeeId,
 	}
@@ -139,37 +566,559 @@ func (db *DynamoStore) UpdateEmployee(employeeId, objectKey, fullName, location,
 		Set(expression.Name("location"), expression.Value(location)).
 		Set(expression.Name("job_title"), expression.Value(jobTitle)).
 		Set(expression.Name("badges"), expression.Value(badges))
+	upd = setProfile(upd, profile)
 
 	expr, _ := expression.NewBuilder().WithUpdate(upd).Build()
 
-	_, err = svc.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(db.table),
-		Key:                       key,
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		UpdateExpression:          expr.Update(),
-		ConditionExpression:       aws.String("attribute_exists(id)"),
+	items := []types.TransactWriteItem{
+		{Update: &types.Update{
+			TableName:                 aws.String(db.table),
+			Key:                       key,
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       aws.String(dynamoEmployeeCondition),
+		}},
+	}
+
+	badgeWrites, err := db.putBadgeItems(employeeId, difference(badges, current.Badges))
+	if err != nil {
+		return fmt.Errorf(errMsg, " MarshalMap", err)
+	}
+	items = append(items, badgeWrites...)
+	items = append(items, db.deleteBadgeItems(employeeId, difference(current.Badges, badges))...)
+
+	teamWrites, err := db.putTeamMemberItems(employeeId, difference(profile.Teams, current.Teams))
+	if err != nil {
+		return fmt.Errorf(errMsg, " MarshalMap", err)
+	}
+	items = append(items, teamWrites...)
+	items = append(items, db.deleteTeamMemberItems(employeeId, difference(current.Teams, profile.Teams))...)
+
+	_, err = svc.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
 	})
 	if err != nil {
-		return fmt.Errorf(errMsg, " UpdateItem", err)
+		return fmt.Errorf(errMsg, " TransactWriteItems", err)
 	}
 
 	return nil
 }
 
+// setProfile writes the profile attributes, removing the empty ones as Put
+// leaves them out through omitempty.
+func setProfile(upd expression.UpdateBuilder, profile model.Profile) expression.UpdateBuilder {
+	attributes := []struct {
+		name  string
+		value interface{}
+		empty bool
+	}{
+		{"email", profile.Email, profile.Email == ""},
+		{"phones", profile.Phones, len(profile.Phones) == 0},
+		{"department", profile.Department, profile.Department == ""},
+		{"manager_id", profile.ManagerId, profile.ManagerId == ""},
+		{"start_date", profile.StartDate, profile.StartDate == ""},
+		{"pronouns", profile.Pronouns, profile.Pronouns == ""},
+		{"timezone", profile.Timezone, profile.Timezone == ""},
+		{"bio", profile.Bio, profile.Bio == ""},
+		{"handles", profile.Handles, len(profile.Handles) == 0},
+		{"teams", profile.Teams, len(profile.Teams) == 0},
+		{"custom", profile.Custom, len(profile.Custom) == 0},
+	}
+
+	for _, a := range attributes {
+		if a.empty {
+			upd = upd.Remove(expression.Name(a.name))
+		} else {
+			upd = upd.Set(expression.Name(a.name), expression.Value(a.value))
+		}
+	}
+	return upd
+}
+
+// DeleteEmployee also removes the manager of their reports, as the SQL
+// stores do with ON DELETE SET NULL. A report whose update fails keeps the
+// id of the deleted manager, and deleting again finishes the job.
 func (db *DynamoStore) DeleteEmployee(employeeId string) error {
 	errMsg := "error to delete employee data%s. Details: '%s'"
 
+	if !isRecordId(employeeId) {
+		return fmt.Errorf(errMsg, "", ErrNotFound)
+	}
+
 	svc, err := db.getDynamoClient()
 	if err != nil {
 		return fmt.Errorf(errMsg, "", err)
 	}
 
+	var badges, teams []string
+	current, err := db.LoadEmployee(employeeId)
+	if err == nil {
+		badges = current.Badges
+		teams = current.Teams
+	}
+
 	selectedKeys := map[string]string{
 		"id": employeeId,
 	}
 	key, _ := attributevalue.MarshalMap(selectedKeys)
 
+	items := []types.TransactWriteItem{
+		{Delete: &types.Delete{
+			TableName:           aws.String(db.table),
+			Key:                 key,
+			ConditionExpression: aws.String("attribute_not_exists(item_type)"),
+		}},
+	}
+	items = append(items, db.deleteBadgeItems(employeeId, badges)...)
+	items = append(items, db.deleteTeamMemberItems(employeeId, teams)...)
+
+	_, err = svc.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		return fmt.Errorf(errMsg, " TransactWriteItems", err)
+	}
+
+	reports, err := db.queryIndex(svc, dynamoManagerIndex, "manager_id", employeeId)
+	if err != nil {
+		return fmt.Errorf(errMsg, " Query", err)
+	}
+
+	// a report moved to another manager meanwhile fails the condition and
+	// is left alone
+	expr, _ := expression.NewBuilder().
+		WithUpdate(expression.Remove(expression.Name("manager_id"))).
+		WithCondition(expression.Name("manager_id").Equal(expression.Value(employeeId))).
+		Build()
+	for _, report := range reports {
+		_, err = svc.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(db.table),
+			Key:                       map[string]types.AttributeValue{"id": report["id"]},
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+		})
+		var ccf *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &ccf) {
+			return fmt.Errorf(errMsg, " UpdateItem", err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DynamoStore) ListCustomFields() ([]model.CustomField, error) {
+	errMsg := "error to get custom fields%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	item, err := db.loadCustomFields(svc)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " GetItem", err)
+	}
+
+	model.SortCustomFields(item.Fields)
+	return item.Fields, nil
+}
+
+func (db *DynamoStore) SaveCustomField(field model.CustomField) error {
+	return db.changeCustomFields("save", func(fields []model.CustomField) []model.CustomField {
+		for i, f := range fields {
+			if f.Key == field.Key {
+				fields[i] = field
+				return fields
+			}
+		}
+		return append(fields, field)
+	})
+}
+
+func (db *DynamoStore) DeleteCustomField(key string) error {
+	return db.changeCustomFields("delete", func(fields []model.CustomField) []model.CustomField {
+		for i, f := range fields {
+			if f.Key == key {
+				return append(fields[:i], fields[i+1:]...)
+			}
+		}
+		return fields
+	})
+}
+
+// changeCustomFields writes the definitions returned by change, failing
+// instead of overwriting when someone else changed them in the meantime.
+func (db *DynamoStore) changeCustomFields(operation string, change func([]model.CustomField) []model.CustomField) error {
+	errMsg := "error to " + operation + " custom field%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	item, err := db.loadCustomFields(svc)
+	if err != nil {
+		return fmt.Errorf(errMsg, " GetItem", err)
+	}
+
+	version := item.Version
+	item.Fields = change(item.Fields)
+	item.Version++
+
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf(errMsg, " MarshalMap", err)
+	}
+
+	cond := expression.Or(
+		expression.AttributeNotExists(expression.Name("id")),
+		expression.Name("version").Equal(expression.Value(version)),
+	)
+	expr, _ := expression.NewBuilder().WithCondition(cond).Build()
+
+	_, err = svc.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:                 aws.String(db.table),
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		return fmt.Errorf(errMsg, " PutItem", err)
+	}
+
+	return nil
+}
+
+func (db *DynamoStore) loadCustomFields(svc *dynamodb.Client) (*customFieldsItem, error) {
+	key, _ := attributevalue.MarshalMap(map[string]string{
+		"id": dynamoCustomFieldsItem,
+	})
+
+	out, err := svc.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName:      aws.String(db.table),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	item := &customFieldsItem{Id: dynamoCustomFieldsItem, ItemType: dynamoSchemaItemType}
+	if out.Item != nil {
+		if err := attributevalue.UnmarshalMap(out.Item, item); err != nil {
+			return nil, err
+		}
+	}
+	if item.Fields == nil {
+		item.Fields = []model.CustomField{}
+	}
+
+	return item, nil
+}
+
+func (db *DynamoStore) ListTeams() ([]*model.Team, error) {
+	errMsg := "error to get team list%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	items, err := db.queryIndex(svc, dynamoItemTypeIndex, "item_type", dynamoTeamItemType)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " Query", err)
+	}
+
+	var teamItems []teamItem
+	err = attributevalue.UnmarshalListOfMaps(items, &teamItems)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+	}
+
+	teams := make([]*model.Team, 0, len(teamItems))
+	for _, item := range teamItems {
+		teams = append(teams, item.team())
+	}
+	model.SortTeams(teams)
+
+	return teams, nil
+}
+
+func (db *DynamoStore) LoadTeam(teamId string) (*model.Team, error) {
+	errMsg := "error to get team data%s. Details: '%s'"
+
+	if !isRecordId(teamId) {
+		return nil, ErrNotFound
+	}
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	key, _ := attributevalue.MarshalMap(map[string]string{
+		"id": newTeamItem(model.Team{Id: teamId}).Id,
+	})
+
+	out, err := svc.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(db.table),
+		Key:       key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " GetItem", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	var item teamItem
+	err = attributevalue.UnmarshalMap(out.Item, &item)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " UnmarshalMap", err)
+	}
+	if item.ItemType != dynamoTeamItemType {
+		return nil, ErrNotFound
+	}
+
+	return item.team(), nil
+}
+
+func (db *DynamoStore) AddTeam(team model.Team) (string, error) {
+	team.Id = uuid.NewString()
+	err := db.putItem("insert team data", newTeamItem(team), expression.AttributeNotExists(expression.Name("id")))
+	if err != nil {
+		return "", err
+	}
+	return team.Id, nil
+}
+
+func (db *DynamoStore) UpdateTeam(team model.Team) error {
+	if !isRecordId(team.Id) {
+		return fmt.Errorf("error to update team data. Details: 'team %s not found'", team.Id)
+	}
+	return db.putItem("update team data", newTeamItem(team), itemOfType(dynamoTeamItemType))
+}
+
+// itemOfType is the condition for replacing an existing item of the given
+// type, and nothing else, with PutItem.
+func itemOfType(itemType string) expression.ConditionBuilder {
+	return expression.AttributeExists(expression.Name("id")).
+		And(expression.Name("item_type").Equal(expression.Value(itemType)))
+}
+
+// putItem writes a team or office item, if cond holds. It tells whether the
+// item exists, so adding cannot overwrite and updating cannot create.
+func (db *DynamoStore) putItem(operation string, v interface{}, cond expression.ConditionBuilder) error {
+	errMsg := "error to " + operation + "%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	item, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return fmt.Errorf(errMsg, " MarshalMap", err)
+	}
+
+	expr, _ := expression.NewBuilder().WithCondition(cond).Build()
+
+	_, err = svc.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:                 aws.String(db.table),
+		Item:                      item,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		return fmt.Errorf(errMsg, " PutItem", err)
+	}
+
+	return nil
+}
+
+// DeleteTeam takes the team out of the profile of each member, along with
+// the membership item, before deleting the team itself. A member whose
+// transaction fails keeps the team, and deleting it again finishes the job.
+func (db *DynamoStore) DeleteTeam(teamId string) error {
+	errMsg := "error to delete team data%s. Details: '%s'"
+
+	if !isRecordId(teamId) {
+		return nil
+	}
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	items, err := db.queryIndex(svc, dynamoTeamIndex, "team_id", teamId)
+	if err != nil {
+		return fmt.Errorf(errMsg, " Query", err)
+	}
+
+	var members []teamMemberItem
+	err = attributevalue.UnmarshalListOfMaps(items, &members)
+	if err != nil {
+		return fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+	}
+
+	for _, member := range members {
+		employee, err := db.LoadEmployee(member.EmployeeId)
+		if err != nil {
+			return fmt.Errorf(errMsg, "", err)
+		}
+
+		key, _ := attributevalue.MarshalMap(map[string]string{
+			"id": member.EmployeeId,
+		})
+
+		var upd expression.UpdateBuilder
+		if teams := difference(employee.Teams, []string{teamId}); len(teams) > 0 {
+			upd = expression.Set(expression.Name("teams"), expression.Value(teams))
+		} else {
+			upd = expression.Remove(expression.Name("teams"))
+		}
+		expr, _ := expression.NewBuilder().WithUpdate(upd).Build()
+
+		writes := []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:                 aws.String(db.table),
+				Key:                       key,
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				UpdateExpression:          expr.Update(),
+				ConditionExpression:       aws.String(dynamoEmployeeCondition),
+			}},
+		}
+		writes = append(writes, db.deleteItems([]string{member.Id})...)
+
+		_, err = svc.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: writes,
+		})
+		if err != nil {
+			return fmt.Errorf(errMsg, " TransactWriteItems", err)
+		}
+	}
+
+	key, _ := attributevalue.MarshalMap(map[string]string{
+		"id": newTeamItem(model.Team{Id: teamId}).Id,
+	})
+
+	_, err = svc.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+		TableName: aws.String(db.table),
+		Key:       key,
+	})
+	if err != nil {
+		return fmt.Errorf(errMsg, " DeleteItem", err)
+	}
+
+	return nil
+}
+
+func (db *DynamoStore) ListOffices() ([]*model.Office, error) {
+	errMsg := "error to get office list%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	items, err := db.queryIndex(svc, dynamoItemTypeIndex, "item_type", dynamoOfficeItemType)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " Query", err)
+	}
+
+	var officeItems []officeItem
+	err = attributevalue.UnmarshalListOfMaps(items, &officeItems)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+	}
+
+	offices := make([]*model.Office, 0, len(officeItems))
+	for _, item := range officeItems {
+		offices = append(offices, item.office())
+	}
+	model.SortOffices(offices)
+
+	return offices, nil
+}
+
+func (db *DynamoStore) LoadOffice(officeId string) (*model.Office, error) {
+	errMsg := "error to get office data%s. Details: '%s'"
+
+	if !isRecordId(officeId) {
+		return nil, ErrNotFound
+	}
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
+
+	key, _ := attributevalue.MarshalMap(map[string]string{
+		"id": newOfficeItem(model.Office{Id: officeId}).Id,
+	})
+
+	out, err := svc.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(db.table),
+		Key:       key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " GetItem", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	var item officeItem
+	err = attributevalue.UnmarshalMap(out.Item, &item)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " UnmarshalMap", err)
+	}
+	if item.ItemType != dynamoOfficeItemType {
+		return nil, ErrNotFound
+	}
+
+	return item.office(), nil
+}
+
+func (db *DynamoStore) AddOffice(office model.Office) (string, error) {
+	office.Id = uuid.NewString()
+	err := db.putItem("insert office data", newOfficeItem(office), expression.AttributeNotExists(expression.Name("id")))
+	if err != nil {
+		return "", err
+	}
+	return office.Id, nil
+}
+
+func (db *DynamoStore) UpdateOffice(office model.Office) error {
+	if !isRecordId(office.Id) {
+		return fmt.Errorf("error to update office data. Details: 'office %s not found'", office.Id)
+	}
+	return db.putItem("update office data", newOfficeItem(office), itemOfType(dynamoOfficeItemType))
+}
+
+func (db *DynamoStore) DeleteOffice(officeId string) error {
+	errMsg := "error to delete office data%s. Details: '%s'"
+
+	if !isRecordId(officeId) {
+		return nil
+	}
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	key, _ := attributevalue.MarshalMap(map[string]string{
+		"id": newOfficeItem(model.Office{Id: officeId}).Id,
+	})
+
 	_, err = svc.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
 		TableName: aws.String(db.table),
 		Key:       key,
@@ -181,21 +1130,288 @@ func (db *DynamoStore) DeleteEmployee(employeeId string) error {
 	return nil
 }
 
-func (db *DynamoStore) IsHealthy() bool {
-	_, err := db.LoadEmployee("unknow")
-	isNotFound := strings.Contains(err.Error(), "data not found")
+// RenameLocation updates the employees found in the location index one by
+// one. Running it again after a failure moves the ones left behind.
+func (db *DynamoStore) RenameLocation(from, to string) error {
+	errMsg := "error to rename location%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	items, err := db.queryIndex(svc, dynamoLocationIndex, "location", from)
+	if err != nil {
+		return fmt.Errorf(errMsg, " Query", err)
+	}
+
+	var emps []*model.Employee
+	err = attributevalue.UnmarshalListOfMaps(items, &emps)
+	if err != nil {
+		return fmt.Errorf(errMsg, " UnmarshalListOfMaps", err)
+	}
+
+	upd := expression.Set(expression.Name("location"), expression.Value(to))
+	cond := expression.Name("location").Equal(expression.Value(from)).
+		And(expression.AttributeNotExists(expression.Name("item_type")))
+	expr, _ := expression.NewBuilder().WithUpdate(upd).WithCondition(cond).Build()
+
+	for _, emp := range emps {
+		key, _ := attributevalue.MarshalMap(map[string]string{
+			"id": emp.Id,
+		})
+
+		_, err = svc.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(db.table),
+			Key:                       key,
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		})
+		if err != nil {
+			return fmt.Errorf(errMsg, " UpdateItem", err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DynamoStore) putBadgeItems(employeeId string, badges []string) ([]types.TransactWriteItem, error) {
+	items := make([]interface{}, 0, len(badges))
+	for _, b := range badges {
+		items = append(items, newBadgeItem(employeeId, b))
+	}
+	return db.putItems(items)
+}
+
+func (db *DynamoStore) deleteBadgeItems(employeeId string, badges []string) []types.TransactWriteItem {
+	ids := make([]string, 0, len(badges))
+	for _, b := range badges {
+		ids = append(ids, newBadgeItem(employeeId, b).Id)
+	}
+	return db.deleteItems(ids)
+}
+
+func (db *DynamoStore) putTeamMemberItems(employeeId string, teams []string) ([]types.TransactWriteItem, error) {
+	items := make([]interface{}, 0, len(teams))
+	for _, t := range teams {
+		items = append(items, newTeamMemberItem(employeeId, t))
+	}
+	return db.putItems(items)
+}
+
+func (db *DynamoStore) deleteTeamMemberItems(employeeId string, teams []string) []types.TransactWriteItem {
+	ids := make([]string, 0, len(teams))
+	for _, t := range teams {
+		ids = append(ids, newTeamMemberItem(employeeId, t).Id)
+	}
+	return db.deleteItems(ids)
+}
 
-	if isNotFound {
-		return true
+func (db *DynamoStore) putItems(items []interface{}) ([]types.TransactWriteItem, error) {
+	writes := make([]types.TransactWriteItem, 0, len(items))
+	for _, i := range items {
+		item, err := attributevalue.MarshalMap(i)
+		if err != nil {
+			return nil, err
+		}
 
-	} else {
-		return false
+		writes = append(writes, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(db.table),
+				Item:      item,
+			},
+		})
 	}
+
+	return writes, nil
+}
+
+func (db *DynamoStore) deleteItems(ids []string) []types.TransactWriteItem {
+	writes := make([]types.TransactWriteItem, 0, len(ids))
+	for _, id := range ids {
+		key, _ := attributevalue.MarshalMap(map[string]string{
+			"id": id,
+		})
+
+		writes = append(writes, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: aws.String(db.table),
+				Key:       key,
+			},
+		})
+	}
+
+	return writes
+}
+
+// difference returns the values of a missing from b.
+func difference(a, b []string) []string {
+	var res []string
+	for _, v := range a {
+		if !containsId(b, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func (db *DynamoStore) Ping(ctx context.Context) error {
+	errMsg := "error to reach dynamo table%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	key, _ := attributevalue.MarshalMap(map[string]string{
+		"id": "unknow",
+	})
+
+	_, err = svc.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.table),
+		Key:       key,
+	})
+	if err != nil {
+		return fmt.Errorf(errMsg, " GetItem", err)
+	}
+
+	return nil
+}
+
+// Migrate brings tables written by older versions up to date, once per
+// table as recorded in the migrations item. It is meant to run in the
+// background after the store opens: until it is done, badge searches scan
+// the table. Backfilling only puts items, so instances starting together
+// may run it at the same time.
+func (db *DynamoStore) Migrate() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.migrated.Load() {
+		return nil
+	}
+
+	errMsg := "error to migrate dynamo table%s. Details: '%s'"
+
+	svc, err := db.getDynamoClient()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	key, _ := attributevalue.MarshalMap(map[string]string{
+		"id": dynamoMigrationsItem,
+	})
+	out, err := svc.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName:      aws.String(db.table),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf(errMsg, " GetItem", err)
+	}
+
+	item := migrationsItem{Id: dynamoMigrationsItem, ItemType: dynamoSchemaItemType}
+	if out.Item != nil {
+		if err := attributevalue.UnmarshalMap(out.Item, &item); err != nil {
+			return fmt.Errorf(errMsg, " UnmarshalMap", err)
+		}
+	}
+
+	if item.Version < dynamoBadgeItemsMigration {
+		if err := db.backfillBadgeItems(svc); err != nil {
+			return fmt.Errorf(errMsg, " backfill badges", err)
+		}
+		item.Version = dynamoBadgeItemsMigration
+
+		av, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return fmt.Errorf(errMsg, " MarshalMap", err)
+		}
+		_, err = svc.PutItem(context.TODO(), &dynamodb.PutItemInput{
+			TableName: aws.String(db.table),
+			Item:      av,
+		})
+		if err != nil {
+			return fmt.Errorf(errMsg, " PutItem", err)
+		}
+	}
+
+	db.migrated.Store(true)
+	return nil
+}
+
+// backfillBadgeItems writes the badge items of every employee, as the ones
+// saved before the badge index have none and badge searches miss them.
+func (db *DynamoStore) backfillBadgeItems(svc *dynamodb.Client) error {
+	filter := expression.AttributeNotExists(expression.Name("item_type"))
+	proj := expression.NamesList(expression.Name("id"), expression.Name("badges"))
+	expr, _ := expression.NewBuilder().WithFilter(filter).WithProjection(proj).Build()
+
+	paginator := dynamodb.NewScanPaginator(svc, &dynamodb.ScanInput{
+		TableName:                 aws.String(db.table),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	var requests []types.WriteRequest
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return err
+		}
+
+		var emps []model.Employee
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &emps); err != nil {
+			return err
+		}
+		for _, emp := range emps {
+			for _, b := range emp.Badges {
+				item, err := attributevalue.MarshalMap(newBadgeItem(emp.Id, b))
+				if err != nil {
+					return err
+				}
+				requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+			}
+		}
+	}
+
+	return db.batchWriteItems(svc, requests)
+}
+
+func (db *DynamoStore) batchWriteItems(svc *dynamodb.Client, requests []types.WriteRequest) error {
+	// BatchWriteItem accepts at most 25 requests at a time
+	const batchSize = 25
+
+	for start := 0; start < len(requests); start += batchSize {
+		end := start + batchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		requestItems := map[string][]types.WriteRequest{
+			db.table: requests[start:end],
+		}
+		for len(requestItems) > 0 {
+			out, err := svc.BatchWriteItem(context.TODO(), &dynamodb.BatchWriteItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return err
+			}
+			requestItems = out.UnprocessedItems
+		}
+	}
+
+	return nil
 }
 
 func (db *DynamoStore) getDynamoClient() (*dynamodb.Client, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO(), func(opts *config.LoadOptions) error {
-		opts.Region = utils.AWS_DEFAULT_REGION
+		opts.Region = db.region
 		return nil
 	})
 	if err != nil {
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+func init() {
+	RegisterPhotoStore("file", func(dsn *url.URL) (PhotoStore, error) {
+		if dsn.Path == "" {
+			return nil, fmt.Errorf("file store url '%s' has no directory", dsn.Redacted())
+		}
+
+		return NewFileStore(dsn.Path), nil
+	})
+}
+
+// FileStore keeps photos in a local directory. It cannot presign URLs, so it
+// hands out links to the application's own /photos/ route instead, relative
+// to the <base href> of the pages so they keep the path prefix.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) FileStore {
+	return FileStore{
+		dir: dir,
+	}
+}
+
+func (s FileStore) path(objectKey string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+objectKey)))
+}
+
+// GeneratePresignedURL points to the photo route of the application, which
+// serves the file; the url does not expire, though it is said to after
+// expiry like the others.
+func (s FileStore) GeneratePresignedURL(objectKey string, expiry time.Duration) (string, time.Time, error) {
+	return "photos/" + path.Clean("/" + objectKey)[1:], time.Now().Add(expiry), nil
+}
+
+// UploadObject writes content to a file. The content type is not kept; the
+// photo route tells it from the content.
+func (s FileStore) UploadObject(objectKey string, content []byte, contentType string) error {
+	errMsg := "error to write photo file%s. Details: '%s'"
+
+	name := s.path(objectKey)
+
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return fmt.Errorf(errMsg, " MkdirAll", err)
+	}
+
+	err = os.WriteFile(name, content, 0644)
+	if err != nil {
+		return fmt.Errorf(errMsg, " WriteFile", err)
+	}
+
+	return nil
+}
+
+func (s FileStore) ReadObject(objectKey string) ([]byte, error) {
+	content, err := os.ReadFile(s.path(objectKey))
+	if err != nil {
+		return nil, fmt.Errorf("error to read photo file. Details: '%s'", err)
+	}
+
+	return content, nil
+}
+
+// DeleteObject removes the file, and its directory once empty, as the one
+// of each photo version becomes.
+func (s FileStore) DeleteObject(objectKey string) error {
+	name := s.path(objectKey)
+
+	err := os.Remove(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error to delete photo file. Details: '%s'", err)
+	}
+
+	// fails while other renditions are left, which is fine
+	os.Remove(filepath.Dir(name))
+
+	return nil
+}
+
+func (s FileStore) Ping(ctx context.Context) error {
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return fmt.Errorf("photo directory is not usable. Details: '%s'", err)
+	}
+
+	return nil
+}
@@ -1,38 +1,178 @@
 package store
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 	"strconv"
+	"sync"
 
 	"github.com/moura1001/aws-employee-directory-application/server/model"
 )
 
+func init() {
+	RegisterEmployeeStore("memory", func(dsn *url.URL) (EmployeeStore, error) {
+		return NewInMemoryStore(), nil
+	})
+}
+
 type InMemoryStore struct {
-	employees []*model.Employee
+	mu           sync.RWMutex
+	employees    []*model.Employee
+	customFields []model.CustomField
+	teams        []*model.Team
+	offices      []*model.Office
+	nextId       int
+	nextTeamId   int
+	nextOfficeId int
 }
 
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
 		employees: []*model.Employee{},
+		teams:     []*model.Team{},
+		offices:   []*model.Office{},
 	}
 }
 
 func (db *InMemoryStore) ListEmployees() ([]*model.Employee, error) {
-	return db.employees, nil
+	return db.filterEmployees(func(e *model.Employee) bool {
+		return true
+	}), nil
+}
+
+func (db *InMemoryStore) ListEmployeesByLocation(location string) ([]*model.Employee, error) {
+	return db.filterEmployees(func(e *model.Employee) bool {
+		return e.Location == location
+	}), nil
+}
+
+func (db *InMemoryStore) ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error) {
+	return db.filterEmployees(func(e *model.Employee) bool {
+		return e.JobTitle == jobTitle
+	}), nil
+}
+
+func (db *InMemoryStore) ListEmployeesByBadge(badge string) ([]*model.Employee, error) {
+	return db.filterEmployees(func(e *model.Employee) bool {
+		return e.HasBadge(badge)
+	}), nil
+}
+
+func (db *InMemoryStore) ListEmployeesByTeam(teamId string) ([]*model.Employee, error) {
+	return db.filterEmployees(func(e *model.Employee) bool {
+		return e.InTeam(teamId)
+	}), nil
+}
+
+func (db *InMemoryStore) ListEmployeesByManager(managerId string) ([]*model.Employee, error) {
+	return db.filterEmployees(func(e *model.Employee) bool {
+		return e.ManagerId != "" && e.ManagerId == managerId
+	}), nil
+}
+
+func (db *InMemoryStore) filterEmployees(match func(e *model.Employee) bool) []*model.Employee {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	res := []*model.Employee{}
+	for _, e := range db.employees {
+		if match(e) {
+			res = append(res, copyEmployee(e))
+		}
+	}
+	return res
 }
 
 func (db *InMemoryStore) LoadEmployee(employeeId string) (*model.Employee, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	for _, e := range db.employees {
 		if e.Id == employeeId {
-			return e, nil
+			return copyEmployee(e), nil
 		}
 	}
-	return nil, nil
+	return nil, ErrNotFound
+}
+
+func (db *InMemoryStore) ListSubordinates(employeeId string) ([]*model.Employee, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	res := []*model.Employee{}
+	found := map[string]bool{employeeId: true}
+	for frontier := []string{employeeId}; len(frontier) > 0; {
+		var next []string
+		for _, e := range db.employees {
+			if e.ManagerId != "" && !found[e.Id] && containsId(frontier, e.ManagerId) {
+				found[e.Id] = true
+				res = append(res, copyEmployee(e))
+				next = append(next, e.Id)
+			}
+		}
+		frontier = next
+	}
+	return res, nil
+}
+
+// copyEmployee returns a copy of e that shares no slice, map or photo with
+// it. Handlers set photo urls on what they load, and the records here are
+// shared by every request.
+func copyEmployee(e *model.Employee) *model.Employee {
+	c := *e
+	if e.Photo != nil {
+		photo := *e.Photo
+		photo.Renditions = copyMap(e.Photo.Renditions)
+		c.Photo = &photo
+	}
+	c.Badges = copyStrings(e.Badges)
+	c.Phones = copyStrings(e.Phones)
+	c.Teams = copyStrings(e.Teams)
+	c.Handles = copyMap(e.Handles)
+	if e.Custom != nil {
+		c.Custom = make(map[string][]string, len(e.Custom))
+		for k, v := range e.Custom {
+			c.Custom[k] = copyStrings(v)
+		}
+	}
+	return &c
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
 }
 
-func (db *InMemoryStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string) (string, error) {
-	id := strconv.Itoa(len(db.employees))
-	db.employees = append(db.employees, &model.Employee{
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func containsId(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *InMemoryStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := strconv.Itoa(db.nextId)
+	db.nextId++
+	db.employees = append(db.employees, copyEmployee(&model.Employee{
 		Id: id,
 		Photo: &model.Photo{
 			ObjectKey: objectKey,
@@ -41,11 +181,15 @@ func (db *InMemoryStore) AddEmployee(objectKey, fullName, location, jobTitle str
 		Location: location,
 		JobTitle: jobTitle,
 		Badges:   badges,
-	})
+		Profile:  profile,
+	}))
 	return id, nil
 }
 
-func (db *InMemoryStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string) error {
+func (db *InMemoryStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var employee *model.Employee = nil
 	for _, e := range db.employees {
 		if e.Id == employeeId {
@@ -54,11 +198,17 @@ func (db *InMemoryStore) UpdateEmployee(employeeId string, objectKey, fullName,
 	}
 
 	if employee != nil {
-		employee.Photo.ObjectKey = objectKey
-		employee.FullName = fullName
-		employee.Location = location
-		employee.JobTitle = jobTitle
-		employee.Badges = badges
+		*employee = *copyEmployee(&model.Employee{
+			Id: employee.Id,
+			Photo: &model.Photo{
+				ObjectKey: objectKey,
+			},
+			FullName: fullName,
+			Location: location,
+			JobTitle: jobTitle,
+			Badges:   badges,
+			Profile:  profile,
+		})
 
 		return nil
 	}
@@ -66,17 +216,225 @@ func (db *InMemoryStore) UpdateEmployee(employeeId string, objectKey, fullName,
 	return fmt.Errorf("employee '%s' does not exist", employeeId)
 }
 
+// DeleteEmployee also clears the manager of their reports, as the SQL
+// stores do with ON DELETE SET NULL.
 func (db *InMemoryStore) DeleteEmployee(employeeId string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	for i, e := range db.employees {
 		if e.Id == employeeId {
 			db.employees = append(db.employees[:i], db.employees[i+1:]...)
+			break
+		}
+	}
+
+	for _, e := range db.employees {
+		if e.ManagerId == employeeId {
+			e.ManagerId = ""
+		}
+	}
+
+	return nil
+}
+
+func (db *InMemoryStore) ListCustomFields() ([]model.CustomField, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	fields := append([]model.CustomField{}, db.customFields...)
+	model.SortCustomFields(fields)
+	return fields, nil
+}
+
+func (db *InMemoryStore) SaveCustomField(field model.CustomField) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, f := range db.customFields {
+		if f.Key == field.Key {
+			db.customFields[i] = field
 			return nil
 		}
 	}
+	db.customFields = append(db.customFields, field)
+	return nil
+}
 
+func (db *InMemoryStore) DeleteCustomField(key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, f := range db.customFields {
+		if f.Key == key {
+			db.customFields = append(db.customFields[:i], db.customFields[i+1:]...)
+			return nil
+		}
+	}
 	return nil
 }
 
-func (db *InMemoryStore) IsHealthy() bool {
-	return true
+func (db *InMemoryStore) ListTeams() ([]*model.Team, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	teams := make([]*model.Team, 0, len(db.teams))
+	for _, t := range db.teams {
+		team := *t
+		teams = append(teams, &team)
+	}
+	model.SortTeams(teams)
+	return teams, nil
+}
+
+func (db *InMemoryStore) LoadTeam(teamId string) (*model.Team, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, t := range db.teams {
+		if t.Id == teamId {
+			team := *t
+			return &team, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (db *InMemoryStore) AddTeam(team model.Team) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	team.Id = strconv.Itoa(db.nextTeamId)
+	db.nextTeamId++
+	db.teams = append(db.teams, &team)
+	return team.Id, nil
+}
+
+func (db *InMemoryStore) UpdateTeam(team model.Team) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, t := range db.teams {
+		if t.Id == team.Id {
+			*t = team
+			return nil
+		}
+	}
+	return fmt.Errorf("team '%s' does not exist", team.Id)
+}
+
+func (db *InMemoryStore) DeleteTeam(teamId string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, t := range db.teams {
+		if t.Id == teamId {
+			db.teams = append(db.teams[:i], db.teams[i+1:]...)
+			break
+		}
+	}
+
+	for _, e := range db.employees {
+		if e.InTeam(teamId) {
+			teams := []string{}
+			for _, id := range e.Teams {
+				if id != teamId {
+					teams = append(teams, id)
+				}
+			}
+			e.Teams = teams
+		}
+	}
+	return nil
+}
+
+func (db *InMemoryStore) ListOffices() ([]*model.Office, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	offices := make([]*model.Office, 0, len(db.offices))
+	for _, o := range db.offices {
+		office := *o
+		offices = append(offices, &office)
+	}
+	model.SortOffices(offices)
+	return offices, nil
+}
+
+func (db *InMemoryStore) LoadOffice(officeId string) (*model.Office, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, o := range db.offices {
+		if o.Id == officeId {
+			office := *o
+			return &office, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (db *InMemoryStore) AddOffice(office model.Office) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, o := range db.offices {
+		if o.Name == office.Name {
+			return "", fmt.Errorf("office '%s' already exists", office.Name)
+		}
+	}
+
+	office.Id = strconv.Itoa(db.nextOfficeId)
+	db.nextOfficeId++
+	db.offices = append(db.offices, &office)
+	return office.Id, nil
+}
+
+func (db *InMemoryStore) UpdateOffice(office model.Office) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var current *model.Office
+	for _, o := range db.offices {
+		if o.Id == office.Id {
+			current = o
+		} else if o.Name == office.Name {
+			return fmt.Errorf("office '%s' already exists", office.Name)
+		}
+	}
+	if current == nil {
+		return fmt.Errorf("office '%s' does not exist", office.Id)
+	}
+
+	*current = office
+	return nil
+}
+
+func (db *InMemoryStore) DeleteOffice(officeId string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, o := range db.offices {
+		if o.Id == officeId {
+			db.offices = append(db.offices[:i], db.offices[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (db *InMemoryStore) RenameLocation(from, to string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, e := range db.employees {
+		if e.Location == from {
+			e.Location = to
+		}
+	}
+	return nil
+}
+
+func (db *InMemoryStore) Ping(ctx context.Context) error {
+	return nil
 }
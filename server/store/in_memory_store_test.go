@@ -0,0 +1,116 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+)
+
+// addEmployee adds an employee reporting to managerId and returns its id.
+func addEmployee(t *testing.T, db *InMemoryStore, name, managerId string) string {
+	t.Helper()
+	id, err := db.AddEmployee("", name, "Seattle", "Engineer", nil, model.Profile{ManagerId: managerId})
+	if err != nil {
+		t.Fatalf("AddEmployee(%q) = %v", name, err)
+	}
+	return id
+}
+
+func ids(employees []*model.Employee) []string {
+	res := []string{}
+	for _, e := range employees {
+		res = append(res, e.Id)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestInMemoryListSubordinates(t *testing.T) {
+	db := NewInMemoryStore()
+	ana := addEmployee(t, db, "Ana", "")
+	bea := addEmployee(t, db, "Bea", ana)
+	cid := addEmployee(t, db, "Cid", bea)
+	dan := addEmployee(t, db, "Dan", cid)
+	addEmployee(t, db, "Eva", "")
+
+	tests := []struct {
+		name       string
+		employeeId string
+		want       []string
+	}{
+		{"every level", ana, []string{bea, cid, dan}},
+		{"below the middle", bea, []string{cid, dan}},
+		{"no reports", dan, []string{}},
+		{"unknown employee", "42", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subordinates, err := db.ListSubordinates(tt.employeeId)
+			if err != nil {
+				t.Fatalf("ListSubordinates(%q) = %v", tt.employeeId, err)
+			}
+			if got := ids(subordinates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListSubordinates(%q) = %v, want %v", tt.employeeId, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryListSubordinatesStopsAtCycles(t *testing.T) {
+	db := NewInMemoryStore()
+	ana := addEmployee(t, db, "Ana", "")
+	bea := addEmployee(t, db, "Bea", ana)
+	cid := addEmployee(t, db, "Cid", bea)
+	if err := db.UpdateEmployee(ana, "", "Ana", "Seattle", "Engineer", nil, model.Profile{ManagerId: cid}); err != nil {
+		t.Fatalf("UpdateEmployee() = %v", err)
+	}
+
+	subordinates, err := db.ListSubordinates(ana)
+	if err != nil {
+		t.Fatalf("ListSubordinates() = %v", err)
+	}
+	if got, want := ids(subordinates), []string{bea, cid}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListSubordinates() = %v, want %v", got, want)
+	}
+}
+
+func TestInMemoryLoadEmployeeNotFound(t *testing.T) {
+	db := NewInMemoryStore()
+
+	if _, err := db.LoadEmployee("42"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadEmployee() = %v, want ErrNotFound", err)
+	}
+}
+
+func TestInMemoryLoadTeamAndOfficeNotFound(t *testing.T) {
+	db := NewInMemoryStore()
+
+	if _, err := db.LoadTeam("42"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadTeam() = %v, want ErrNotFound", err)
+	}
+	if _, err := db.LoadOffice("42"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadOffice() = %v, want ErrNotFound", err)
+	}
+}
+
+func TestInMemoryDeleteEmployeeClearsManager(t *testing.T) {
+	db := NewInMemoryStore()
+	ana := addEmployee(t, db, "Ana", "")
+	bea := addEmployee(t, db, "Bea", ana)
+
+	if err := db.DeleteEmployee(ana); err != nil {
+		t.Fatalf("DeleteEmployee() = %v", err)
+	}
+
+	employee, err := db.LoadEmployee(bea)
+	if err != nil {
+		t.Fatalf("LoadEmployee() = %v", err)
+	}
+	if employee.ManagerId != "" {
+		t.Errorf("ManagerId = %q after the manager was deleted, want none", employee.ManagerId)
+	}
+}
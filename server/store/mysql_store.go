@@ -4,51 +4,169 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/moura1001/aws-employee-directory-application/server/model"
-	"github.com/moura1001/aws-employee-directory-application/server/utils"
+)
+
+func init() {
+	RegisterEmployeeStore("mysql", func(dsn *url.URL) (EmployeeStore, error) {
+		cfg := mysql.NewConfig()
+		cfg.Net = "tcp"
+		cfg.Addr = dsn.Host
+		cfg.DBName = strings.TrimPrefix(dsn.Path, "/")
+		cfg.User = dsn.User.Username()
+		cfg.Passwd, _ = dsn.User.Password()
+
+		params := map[string]string{}
+		for key := range dsn.Query() {
+			params[key] = dsn.Query().Get(key)
+		}
+		if len(params) > 0 {
+			cfg.Params = params
+		}
+
+		return NewMysqlStore(cfg.FormatDSN()), nil
+	})
+}
+
+// mysqlColumns are missing from databases created by an init_db.sql older
+// than the profile fields.
+var mysqlColumns = []column{
+	{"email", "nvarchar(254) not null default ''"},
+	{"phones", "nvarchar(200) not null default ''"},
+	{"department", "nvarchar(200) not null default ''"},
+	{"manager_id", "int null"},
+	{"start_date", "date null"},
+	{"pronouns", "nvarchar(40) not null default ''"},
+	{"timezone", "varchar(64) not null default ''"},
+	{"bio", "text null"},
+	{"handles", "json null"},
+	{"custom_fields", "json null"},
+}
+
+// mysqlTables are created when missing, in this order, as the later ones
+// reference the earlier.
+var mysqlTables = []string{`
+CREATE TABLE IF NOT EXISTS custom_field (
+  field_key varchar(64) not null primary key,
+  label nvarchar(200) not null,
+  field_type varchar(20) not null,
+  options json null,
+  required boolean not null default false,
+  visibility varchar(20) not null default 'public',
+  position int not null default 0
+)`, `
+CREATE TABLE IF NOT EXISTS team (
+  id int not null auto_increment primary key,
+  name nvarchar(200) not null,
+  kind varchar(20) not null default 'team',
+  description text null,
+  lead_id int null,
+  CONSTRAINT fk_team_lead FOREIGN KEY (lead_id) REFERENCES employee(id) ON DELETE SET NULL
+)`, `
+CREATE TABLE IF NOT EXISTS team_member (
+  team_id int not null,
+  employee_id int not null,
+  PRIMARY KEY (team_id, employee_id),
+  INDEX idx_team_member_employee (employee_id),
+  CONSTRAINT fk_team_member_team FOREIGN KEY (team_id) REFERENCES team(id) ON DELETE CASCADE,
+  CONSTRAINT fk_team_member_employee FOREIGN KEY (employee_id) REFERENCES employee(id) ON DELETE CASCADE
+)`, `
+CREATE TABLE IF NOT EXISTS office (
+  id int not null auto_increment primary key,
+  name nvarchar(200) not null,
+  address text null,
+  timezone varchar(64) not null default '',
+  latitude double null,
+  longitude double null,
+  UNIQUE INDEX idx_office_name (name)
+)`,
+}
 
-	_ "github.com/go-sql-driver/mysql"
+const (
+	mysqlDeleteTeams = "DELETE FROM team_member WHERE employee_id=?"
+	mysqlInsertTeam  = "INSERT INTO team_member(team_id, employee_id) VALUES(?,?)"
 )
 
-type MysqlStore struct{}
+// MysqlStore keeps a single connection pool, opened and migrated on first
+// use.
+type MysqlStore struct {
+	connectStr string
+	mu         sync.Mutex
+	conn       *sql.DB
+}
 
-func NewMysqlStore() *MysqlStore {
-	return new(MysqlStore)
+func NewMysqlStore(connectStr string) *MysqlStore {
+	return &MysqlStore{
+		connectStr: connectStr,
+	}
 }
 
 func (db *MysqlStore) ListEmployees() ([]*model.Employee, error) {
+	return db.listEmployees("")
+}
+
+func (db *MysqlStore) ListEmployeesByLocation(location string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE location=?", location)
+}
+
+func (db *MysqlStore) ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE job_title=?", jobTitle)
+}
+
+func (db *MysqlStore) ListEmployeesByBadge(badge string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE FIND_IN_SET(?, badges) > 0", badge)
+}
+
+func (db *MysqlStore) ListEmployeesByTeam(teamId string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE id IN (SELECT employee_id FROM team_member WHERE team_id=?)", teamId)
+}
+
+func (db *MysqlStore) ListEmployeesByManager(managerId string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE manager_id=?", managerId)
+}
+
+// ListSubordinates walks the reporting lines with a recursive query. UNION,
+// rather than UNION ALL, stops at employees already found, so a cycle left
+// in the data cannot make it loop.
+func (db *MysqlStore) ListSubordinates(employeeId string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE id IN (WITH RECURSIVE subtree (id) AS (SELECT id FROM employee WHERE manager_id=? UNION SELECT e.id FROM employee e JOIN subtree s ON e.manager_id=s.id) SELECT id FROM subtree)", employeeId)
+}
+
+func (db *MysqlStore) listEmployees(where string, args ...interface{}) ([]*model.Employee, error) {
 	errMsg := "error to get employee list. Details: '%s'"
 	conn, err := db.getDatabaseConnection()
 
 	if err == nil {
-		defer conn.Close()
-
-		selEmp, err := conn.Query("SELECT id, object_key, full_name, location, job_title, badges FROM employee ORDER BY id DESC")
+		query := "SELECT id, object_key, full_name, location, job_title, badges, " + profileColumns + ", " + teamIdsColumn + " FROM employee " + where + " ORDER BY id DESC"
+		selEmp, err := conn.Query(query, args...)
 		if err != nil {
 			return nil, fmt.Errorf(errMsg, err)
 		}
+		defer selEmp.Close()
 
 		res := []*model.Employee{}
 		for selEmp.Next() {
 			emp := &model.Employee{Photo: new(model.Photo)}
 			var b string
-			err = selEmp.Scan(&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &(emp.JobTitle), &b)
-			if err == nil {
-
-				var badges []string
-				if len(b) > 0 {
-					badges = strings.Split(b, ",")
-				} else {
-					badges = []string{}
-				}
-				emp.Badges = badges
-
-				res = append(res, emp)
+			var p profileRow
+			err = selEmp.Scan(append([]interface{}{&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &(emp.JobTitle), &b}, p.dest()...)...)
+			if err != nil {
+				return nil, fmt.Errorf(errMsg, err)
 			}
+			emp.Profile = p.profile()
+			emp.Badges = splitBadges(b)
+
+			res = append(res, emp)
+		}
+		if err := selEmp.Err(); err != nil {
+			return nil, fmt.Errorf(errMsg, err)
 		}
 
 		return res, nil
@@ -63,61 +181,64 @@ func (db *MysqlStore) LoadEmployee(employeeId string) (*model.Employee, error) {
 	conn, err := db.getDatabaseConnection()
 
 	if err == nil {
-		defer conn.Close()
-
-		selEmp, err := conn.Query("SELECT id, object_key, full_name, location, job_title, badges FROM employee WHERE id=?", employeeId)
-		if err != nil {
+		emp := &model.Employee{Photo: new(model.Photo)}
+		var b string
+		var p profileRow
+		err = conn.QueryRow("SELECT id, object_key, full_name, location, job_title, badges, "+profileColumns+", "+teamIdsColumn+" FROM employee WHERE id=?", employeeId).
+			Scan(append([]interface{}{&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &(emp.JobTitle), &b}, p.dest()...)...)
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		} else if err != nil {
 			return nil, fmt.Errorf(errMsg, err)
 		}
 
-		emp := &model.Employee{Photo: new(model.Photo)}
-		for selEmp.Next() {
-			var b string
-			err = selEmp.Scan(&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &emp.JobTitle, &b)
-			if err == nil {
-				var badges []string
-				if len(b) > 0 {
-					badges = strings.Split(b, ",")
-				} else {
-					badges = []string{}
-				}
-				emp.Badges = badges
-			}
-		}
+		emp.Profile = p.profile()
+		emp.Badges = splitBadges(b)
 
-		if emp.Id != "" {
-			return emp, nil
-		} else {
-			return nil, nil
-		}
+		return emp, nil
 
 	} else {
 		return nil, fmt.Errorf(errMsg, err)
 	}
 }
 
-func (db *MysqlStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string) (string, error) {
+func (db *MysqlStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error) {
 	errMsg := "error to insert employee data. Details: '%s'"
 	conn, err := db.getDatabaseConnection()
 
 	if err == nil {
-		defer conn.Close()
+		// the employee and their teams are written together
+		tx, err := conn.Begin()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+		defer tx.Rollback()
 
-		query := "INSERT INTO employee(object_key, full_name, location, job_title, badges) VALUES(?,?,?,?,?)"
+		query := "INSERT INTO employee(object_key, full_name, location, job_title, badges, " + profileColumns + ") VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
 
 		b := strings.Join(badges, ",")
 
-		_, err = conn.Exec(query, objectKey, fullName, location, jobTitle, b)
+		_, err = tx.Exec(query, append([]interface{}{objectKey, fullName, location, jobTitle, b}, profileArgs(profile)...)...)
 		if err != nil {
 			return "", fmt.Errorf(errMsg, err)
 		}
 
 		var empId string
-		err = conn.QueryRow("SELECT LAST_INSERT_ID()").Scan(&empId)
+		err = tx.QueryRow("SELECT LAST_INSERT_ID()").Scan(&empId)
 		if err != nil {
 			return "", fmt.Errorf(errMsg, fmt.Errorf("failed to get last inserted id: '%s'", err))
 		}
 
+		err = setTeams(tx, mysqlDeleteTeams, mysqlInsertTeam, empId, profile.Teams)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
 		return empId, nil
 
 	} else {
@@ -125,7 +246,7 @@ func (db *MysqlStore) AddEmployee(objectKey, fullName, location, jobTitle string
 	}
 }
 
-func (db *MysqlStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string) error {
+func (db *MysqlStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error {
 	errMsg := "error to update employee data. Details: '%s'"
 
 	empId, err := strconv.ParseInt(employeeId, 10, 32)
@@ -136,19 +257,36 @@ func (db *MysqlStore) UpdateEmployee(employeeId string, objectKey, fullName, loc
 	conn, err := db.getDatabaseConnection()
 
 	if err == nil {
-		defer conn.Close()
+		// the employee and their teams are written together
+		tx, err := conn.Begin()
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+		defer tx.Rollback()
 
 		query := "SELECT id FROM employee WHERE id=?"
-		err = conn.QueryRow(query, empId).Scan(&empId)
+		err = tx.QueryRow(query, empId).Scan(&empId)
 		if err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
 
-		query = "UPDATE employee SET object_key=?, full_name=?, location=?, job_title=?, badges=? WHERE id=?"
+		query = "UPDATE employee SET object_key=?, full_name=?, location=?, job_title=?, badges=?, " +
+			"email=?, phones=?, department=?, manager_id=?, start_date=?, pronouns=?, timezone=?, bio=?, handles=?, custom_fields=? WHERE id=?"
 
 		b := strings.Join(badges, ",")
 
-		_, err = conn.Exec(query, objectKey, fullName, location, jobTitle, b, empId)
+		args := append([]interface{}{objectKey, fullName, location, jobTitle, b}, profileArgs(profile)...)
+		_, err = tx.Exec(query, append(args, empId)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		err = setTeams(tx, mysqlDeleteTeams, mysqlInsertTeam, empId, profile.Teams)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		err = tx.Commit()
 		if err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
@@ -165,8 +303,6 @@ func (db *MysqlStore) DeleteEmployee(employeeId string) error {
 	conn, err := db.getDatabaseConnection()
 
 	if err == nil {
-		defer conn.Close()
-
 		delEmp, err := conn.Prepare("DELETE FROM employee WHERE id=?")
 		if err != nil {
 			return fmt.Errorf(errMsg, err)
@@ -185,29 +321,390 @@ func (db *MysqlStore) DeleteEmployee(employeeId string) error {
 	}
 }
 
-func (db *MysqlStore) IsHealthy() bool {
+func (db *MysqlStore) ListCustomFields() ([]model.CustomField, error) {
+	errMsg := "error to get custom fields. Details: '%s'"
 	conn, err := db.getDatabaseConnection()
 
 	if err == nil {
-		defer conn.Close()
+		rows, err := conn.Query("SELECT " + customFieldColumns + " FROM custom_field")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
 
-		return true
+		fields, err := scanCustomFields(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return fields, nil
 
 	} else {
-		return false
+		return nil, fmt.Errorf(errMsg, err)
 	}
 }
 
-func (db *MysqlStore) getDatabaseConnection() (*sql.DB, error) {
-	connectStr := fmt.Sprintf("%s:%s@(%s)/%s", utils.DATABASE_USER, utils.DATABASE_PASSWORD, utils.DATABASE_HOST, utils.DATABASE_DB_NAME)
-	conn, err := sql.Open("mysql", connectStr)
+func (db *MysqlStore) SaveCustomField(field model.CustomField) error {
+	errMsg := "error to save custom field. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		query := "INSERT INTO custom_field(" + customFieldColumns + ") VALUES(?,?,?,?,?,?,?) " +
+			"ON DUPLICATE KEY UPDATE label=VALUES(label), field_type=VALUES(field_type), options=VALUES(options), " +
+			"required=VALUES(required), visibility=VALUES(visibility), position=VALUES(position)"
+
+		_, err = conn.Exec(query, customFieldArgs(field)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) DeleteCustomField(key string) error {
+	errMsg := "error to delete custom field. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM custom_field WHERE field_key=?", key)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) ListTeams() ([]*model.Team, error) {
+	errMsg := "error to get team list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + teamColumns + " FROM team ORDER BY name")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		teams, err := scanTeams(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return teams, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) LoadTeam(teamId string) (*model.Team, error) {
+	errMsg := "error to get team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT "+teamColumns+" FROM team WHERE id=?", teamId)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		teams, err := scanTeams(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		if len(teams) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return teams[0], nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) AddTeam(team model.Team) (string, error) {
+	errMsg := "error to insert team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("INSERT INTO team(name, kind, description, lead_id) VALUES(?,?,?,?)", teamArgs(team)...)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		teamId, err := res.LastInsertId()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, fmt.Errorf("failed to get last inserted id: '%s'", err))
+		}
+
+		return strconv.FormatInt(teamId, 10), nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) UpdateTeam(team model.Team) error {
+	errMsg := "error to update team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		var teamId string
+		err = conn.QueryRow("SELECT id FROM team WHERE id=?", team.Id).Scan(&teamId)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("team '%s' does not exist", team.Id)
+		} else if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		_, err = conn.Exec("UPDATE team SET name=?, kind=?, description=?, lead_id=? WHERE id=?", append(teamArgs(team), teamId)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+// DeleteTeam leaves the memberships to the ON DELETE CASCADE of
+// team_member.
+func (db *MysqlStore) DeleteTeam(teamId string) error {
+	errMsg := "error to delete team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM team WHERE id=?", teamId)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) ListOffices() ([]*model.Office, error) {
+	errMsg := "error to get office list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + officeColumns + " FROM office ORDER BY name")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		offices, err := scanOffices(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return offices, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) LoadOffice(officeId string) (*model.Office, error) {
+	errMsg := "error to get office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT "+officeColumns+" FROM office WHERE id=?", officeId)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		offices, err := scanOffices(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		if len(offices) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return offices[0], nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) AddOffice(office model.Office) (string, error) {
+	errMsg := "error to insert office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("INSERT INTO office(name, address, timezone, latitude, longitude) VALUES(?,?,?,?,?)", officeArgs(office)...)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		officeId, err := res.LastInsertId()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, fmt.Errorf("failed to get last inserted id: '%s'", err))
+		}
+
+		return strconv.FormatInt(officeId, 10), nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) UpdateOffice(office model.Office) error {
+	errMsg := "error to update office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("UPDATE office SET name=?, address=?, timezone=?, latitude=?, longitude=? WHERE id=?", append(officeArgs(office), office.Id)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("office '%s' does not exist", office.Id)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) DeleteOffice(officeId string) error {
+	errMsg := "error to delete office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM office WHERE id=?", officeId)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) RenameLocation(from, to string) error {
+	errMsg := "error to rename location. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
 
 	if err == nil {
-		ctx, canc := context.WithTimeout(context.Background(), time.Millisecond*100)
-		defer canc()
-		err = conn.PingContext(ctx)
-		return conn, err
+		_, err = conn.Exec("UPDATE employee SET location=? WHERE location=?", to, from)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
 	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *MysqlStore) Ping(ctx context.Context) error {
+	conn, err := db.getDatabaseConnection()
+	if err != nil {
+		return err
+	}
+
+	return conn.PingContext(ctx)
+}
+
+func (db *MysqlStore) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn == nil {
+		return nil
+	}
+
+	err := db.conn.Close()
+	db.conn = nil
+
+	return err
+}
+
+// migrate adds the columns, tables and the manager foreign key that
+// databases created by an older init_db.sql lack. MySQL has no ADD COLUMN IF NOT
+// EXISTS, so the current columns are looked up first.
+func (db *MysqlStore) migrate(conn *sql.DB) error {
+	errMsg := "error to migrate mysql schema. Details: '%s'"
+
+	rows, err := conn.Query("SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME='employee'")
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			existing[strings.ToLower(name)] = true
+		}
+	}
+	rows.Close()
+
+	if err := addColumns(conn, existing, mysqlColumns); err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	for _, table := range mysqlTables {
+		if _, err := conn.Exec(table); err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+	}
+
+	var fk int
+	err = conn.QueryRow("SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME='employee' AND CONSTRAINT_NAME='fk_employee_manager'").Scan(&fk)
+	if err == nil && fk == 0 {
+		_, err = conn.Exec("ALTER TABLE employee ADD CONSTRAINT fk_employee_manager FOREIGN KEY (manager_id) REFERENCES employee(id) ON DELETE SET NULL")
+	}
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	return nil
+}
+
+func (db *MysqlStore) getDatabaseConnection() (*sql.DB, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn != nil {
+		return db.conn, nil
+	}
+
+	conn, err := sql.Open("mysql", db.connectStr)
+	if err != nil {
 		return nil, fmt.Errorf("error to open mysql database connection: Details: '%s'", err)
 	}
+
+	ctx, canc := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer canc()
+	err = conn.PingContext(ctx)
+	if err == nil {
+		err = db.migrate(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	db.conn = conn
+
+	return conn, nil
 }
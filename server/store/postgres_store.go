@@ -0,0 +1,718 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+)
+
+func init() {
+	factory := func(dsn *url.URL) (EmployeeStore, error) {
+		return NewPostgresStore(dsn.String()), nil
+	}
+	RegisterEmployeeStore("postgres", factory)
+	RegisterEmployeeStore("postgresql", factory)
+}
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS employee (
+  id serial primary key,
+  object_key varchar(80) not null default '',
+  full_name varchar(200) not null,
+  location varchar(200) not null,
+  job_title varchar(200) not null,
+  badges text[] not null default '{}',
+  created_datetime timestamp default now()
+);
+CREATE INDEX IF NOT EXISTS idx_employee_location ON employee (location);
+CREATE INDEX IF NOT EXISTS idx_employee_job_title ON employee (job_title);
+CREATE INDEX IF NOT EXISTS idx_employee_badges ON employee USING GIN (badges);
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS email varchar(254) not null default '';
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS phones text[] not null default '{}';
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS department varchar(200) not null default '';
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS manager_id integer references employee(id) on delete set null;
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS start_date date;
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS pronouns varchar(40) not null default '';
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS timezone varchar(64) not null default '';
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS bio text;
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS handles jsonb;
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS custom_fields jsonb;
+CREATE TABLE IF NOT EXISTS custom_field (
+  field_key varchar(64) primary key,
+  label varchar(200) not null,
+  field_type varchar(20) not null,
+  options jsonb,
+  required boolean not null default false,
+  visibility varchar(20) not null default 'public',
+  position integer not null default 0
+);
+CREATE TABLE IF NOT EXISTS team (
+  id serial primary key,
+  name varchar(200) not null,
+  kind varchar(20) not null default 'team',
+  description text,
+  lead_id integer references employee(id) on delete set null
+);
+CREATE TABLE IF NOT EXISTS team_member (
+  team_id integer not null references team(id) on delete cascade,
+  employee_id integer not null references employee(id) on delete cascade,
+  primary key (team_id, employee_id)
+);
+CREATE INDEX IF NOT EXISTS idx_team_member_employee ON team_member (employee_id);
+CREATE TABLE IF NOT EXISTS office (
+  id serial primary key,
+  name varchar(200) not null unique,
+  address text,
+  timezone varchar(64) not null default '',
+  latitude double precision,
+  longitude double precision
+);
+`
+
+// postgresTeamIdsColumn is teamIdsColumn with string_agg.
+const postgresTeamIdsColumn = "(SELECT string_agg(team_id::text, ',') FROM team_member WHERE team_member.employee_id=employee.id)"
+
+const (
+	postgresDeleteTeams = "DELETE FROM team_member WHERE employee_id=$1"
+	postgresInsertTeam  = "INSERT INTO team_member(team_id, employee_id) VALUES($1,$2)"
+)
+
+// postgresProfileColumns reads phones and start_date back in the text form
+// used by the other SQL stores.
+const postgresProfileColumns = "email, array_to_string(phones, ','), department, manager_id, to_char(start_date, 'YYYY-MM-DD'), pronouns, timezone, bio, handles, custom_fields"
+
+// PostgresStore keeps a single connection pool, opened and migrated on first
+// use.
+type PostgresStore struct {
+	connectStr string
+	mu         sync.Mutex
+	conn       *sql.DB
+}
+
+func NewPostgresStore(connectStr string) *PostgresStore {
+	return &PostgresStore{
+		connectStr: connectStr,
+	}
+}
+
+func (db *PostgresStore) ListEmployees() ([]*model.Employee, error) {
+	return db.listEmployees("")
+}
+
+func (db *PostgresStore) ListEmployeesByLocation(location string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE location=$1", location)
+}
+
+func (db *PostgresStore) ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE job_title=$1", jobTitle)
+}
+
+func (db *PostgresStore) ListEmployeesByBadge(badge string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE badges @> ARRAY[$1]::text[]", badge)
+}
+
+func (db *PostgresStore) ListEmployeesByTeam(teamId string) ([]*model.Employee, error) {
+	id, err := strconv.ParseInt(teamId, 10, 32)
+	if err != nil {
+		return []*model.Employee{}, nil
+	}
+
+	return db.listEmployees("WHERE id IN (SELECT employee_id FROM team_member WHERE team_id=$1)", id)
+}
+
+func (db *PostgresStore) ListEmployeesByManager(managerId string) ([]*model.Employee, error) {
+	id, err := strconv.ParseInt(managerId, 10, 32)
+	if err != nil {
+		return []*model.Employee{}, nil
+	}
+
+	return db.listEmployees("WHERE manager_id=$1", id)
+}
+
+// ListSubordinates uses the same recursive query as the MySQL store.
+func (db *PostgresStore) ListSubordinates(employeeId string) ([]*model.Employee, error) {
+	empId, err := strconv.ParseInt(employeeId, 10, 32)
+	if err != nil {
+		return []*model.Employee{}, nil
+	}
+
+	return db.listEmployees("WHERE id IN (WITH RECURSIVE subtree (id) AS (SELECT id FROM employee WHERE manager_id=$1 UNION SELECT e.id FROM employee e JOIN subtree s ON e.manager_id=s.id) SELECT id FROM subtree)", empId)
+}
+
+func (db *PostgresStore) listEmployees(where string, args ...interface{}) ([]*model.Employee, error) {
+	errMsg := "error to get employee list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		query := "SELECT id, object_key, full_name, location, job_title, badges, " + postgresProfileColumns + ", " + postgresTeamIdsColumn + " FROM employee " + where + " ORDER BY id DESC"
+		selEmp, err := conn.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		defer selEmp.Close()
+
+		res := []*model.Employee{}
+		for selEmp.Next() {
+			emp := &model.Employee{Photo: new(model.Photo)}
+			var badges []string
+			var p profileRow
+			err = selEmp.Scan(append([]interface{}{&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &(emp.JobTitle), pq.Array(&badges)}, p.dest()...)...)
+			if err != nil {
+				return nil, fmt.Errorf(errMsg, err)
+			}
+			emp.Profile = p.profile()
+			if badges == nil {
+				badges = []string{}
+			}
+			emp.Badges = badges
+
+			res = append(res, emp)
+		}
+		if err := selEmp.Err(); err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return res, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) LoadEmployee(employeeId string) (*model.Employee, error) {
+	errMsg := "error to get employee data. Details: '%s'"
+
+	empId, err := strconv.ParseInt(employeeId, 10, 32)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		emp := &model.Employee{Photo: new(model.Photo)}
+		var badges []string
+		var p profileRow
+		err = conn.QueryRow("SELECT id, object_key, full_name, location, job_title, badges, "+postgresProfileColumns+", "+postgresTeamIdsColumn+" FROM employee WHERE id=$1", empId).
+			Scan(append([]interface{}{&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &(emp.JobTitle), pq.Array(&badges)}, p.dest()...)...)
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		if badges == nil {
+			badges = []string{}
+		}
+		emp.Badges = badges
+		emp.Profile = p.profile()
+
+		return emp, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error) {
+	errMsg := "error to insert employee data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		// the employee and their teams are written together
+		tx, err := conn.Begin()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+		defer tx.Rollback()
+
+		query := "INSERT INTO employee(object_key, full_name, location, job_title, badges, " + profileColumns + ") " +
+			"VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING id"
+
+		var empId string
+		err = tx.QueryRow(query, append([]interface{}{objectKey, fullName, location, jobTitle, pq.Array(badges)}, postgresProfileArgs(profile)...)...).Scan(&empId)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		err = setTeams(tx, postgresDeleteTeams, postgresInsertTeam, empId, profile.Teams)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		return empId, nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error {
+	errMsg := "error to update employee data. Details: '%s'"
+
+	empId, err := strconv.ParseInt(employeeId, 10, 32)
+	if err != nil {
+		return fmt.Errorf("employee '%s' does not exist", employeeId)
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		// the employee and their teams are written together
+		tx, err := conn.Begin()
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+		defer tx.Rollback()
+
+		query := "UPDATE employee SET object_key=$1, full_name=$2, location=$3, job_title=$4, badges=$5, " +
+			"email=$6, phones=$7, department=$8, manager_id=$9, start_date=$10, pronouns=$11, timezone=$12, bio=$13, handles=$14, custom_fields=$15 WHERE id=$16"
+
+		args := append([]interface{}{objectKey, fullName, location, jobTitle, pq.Array(badges)}, postgresProfileArgs(profile)...)
+		res, err := tx.Exec(query, append(args, empId)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("employee '%s' does not exist", employeeId)
+		}
+
+		err = setTeams(tx, postgresDeleteTeams, postgresInsertTeam, empId, profile.Teams)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) DeleteEmployee(employeeId string) error {
+	errMsg := "error to delete employee data. Details: '%s'"
+
+	empId, err := strconv.ParseInt(employeeId, 10, 32)
+	if err != nil {
+		return nil
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM employee WHERE id=$1", empId)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) ListCustomFields() ([]model.CustomField, error) {
+	errMsg := "error to get custom fields. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + customFieldColumns + " FROM custom_field")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		fields, err := scanCustomFields(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return fields, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) SaveCustomField(field model.CustomField) error {
+	errMsg := "error to save custom field. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		query := "INSERT INTO custom_field(" + customFieldColumns + ") VALUES($1,$2,$3,$4,$5,$6,$7) " +
+			"ON CONFLICT (field_key) DO UPDATE SET label=EXCLUDED.label, field_type=EXCLUDED.field_type, options=EXCLUDED.options, " +
+			"required=EXCLUDED.required, visibility=EXCLUDED.visibility, position=EXCLUDED.position"
+
+		_, err = conn.Exec(query, customFieldArgs(field)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) DeleteCustomField(key string) error {
+	errMsg := "error to delete custom field. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM custom_field WHERE field_key=$1", key)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) ListTeams() ([]*model.Team, error) {
+	errMsg := "error to get team list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + teamColumns + " FROM team ORDER BY name")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		teams, err := scanTeams(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return teams, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) LoadTeam(teamId string) (*model.Team, error) {
+	errMsg := "error to get team data. Details: '%s'"
+
+	id, err := strconv.ParseInt(teamId, 10, 32)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT "+teamColumns+" FROM team WHERE id=$1", id)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		teams, err := scanTeams(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		if len(teams) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return teams[0], nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) AddTeam(team model.Team) (string, error) {
+	errMsg := "error to insert team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		var teamId string
+		err = conn.QueryRow("INSERT INTO team(name, kind, description, lead_id) VALUES($1,$2,$3,$4) RETURNING id", teamArgs(team)...).Scan(&teamId)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		return teamId, nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) UpdateTeam(team model.Team) error {
+	errMsg := "error to update team data. Details: '%s'"
+
+	id, err := strconv.ParseInt(team.Id, 10, 32)
+	if err != nil {
+		return fmt.Errorf("team '%s' does not exist", team.Id)
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("UPDATE team SET name=$1, kind=$2, description=$3, lead_id=$4 WHERE id=$5", append(teamArgs(team), id)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("team '%s' does not exist", team.Id)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) DeleteTeam(teamId string) error {
+	errMsg := "error to delete team data. Details: '%s'"
+
+	id, err := strconv.ParseInt(teamId, 10, 32)
+	if err != nil {
+		return nil
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM team WHERE id=$1", id)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) ListOffices() ([]*model.Office, error) {
+	errMsg := "error to get office list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + officeColumns + " FROM office ORDER BY name")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		offices, err := scanOffices(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return offices, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) LoadOffice(officeId string) (*model.Office, error) {
+	errMsg := "error to get office data. Details: '%s'"
+	id, err := strconv.ParseInt(officeId, 10, 32)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT "+officeColumns+" FROM office WHERE id=$1", id)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		offices, err := scanOffices(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		if len(offices) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return offices[0], nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) AddOffice(office model.Office) (string, error) {
+	errMsg := "error to insert office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		var officeId string
+		err = conn.QueryRow("INSERT INTO office(name, address, timezone, latitude, longitude) VALUES($1,$2,$3,$4,$5) RETURNING id", officeArgs(office)...).Scan(&officeId)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		return officeId, nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) UpdateOffice(office model.Office) error {
+	errMsg := "error to update office data. Details: '%s'"
+	id, err := strconv.ParseInt(office.Id, 10, 32)
+	if err != nil {
+		return fmt.Errorf("office '%s' does not exist", office.Id)
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("UPDATE office SET name=$1, address=$2, timezone=$3, latitude=$4, longitude=$5 WHERE id=$6", append(officeArgs(office), id)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("office '%s' does not exist", office.Id)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) DeleteOffice(officeId string) error {
+	errMsg := "error to delete office data. Details: '%s'"
+	id, err := strconv.ParseInt(officeId, 10, 32)
+	if err != nil {
+		return nil
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM office WHERE id=$1", id)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) RenameLocation(from, to string) error {
+	errMsg := "error to rename location. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("UPDATE employee SET location=$1 WHERE location=$2", to, from)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *PostgresStore) Ping(ctx context.Context) error {
+	conn, err := db.getDatabaseConnection()
+	if err != nil {
+		return err
+	}
+
+	return conn.PingContext(ctx)
+}
+
+func (db *PostgresStore) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn == nil {
+		return nil
+	}
+
+	err := db.conn.Close()
+	db.conn = nil
+
+	return err
+}
+
+// postgresProfileArgs is profileArgs with the phones as an array.
+func postgresProfileArgs(profile model.Profile) []interface{} {
+	phones := profile.Phones
+	if phones == nil {
+		phones = []string{}
+	}
+	args := profileArgs(profile)
+	args[1] = pq.Array(phones)
+	return args
+}
+
+func (db *PostgresStore) migrate(conn *sql.DB) error {
+	_, err := conn.Exec(postgresSchema)
+	if err != nil {
+		return fmt.Errorf("error to migrate postgres schema. Details: '%s'", err)
+	}
+	return nil
+}
+
+func (db *PostgresStore) getDatabaseConnection() (*sql.DB, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn != nil {
+		return db.conn, nil
+	}
+
+	conn, err := sql.Open("postgres", db.connectStr)
+	if err != nil {
+		return nil, fmt.Errorf("error to open postgres database connection: Details: '%s'", err)
+	}
+
+	ctx, canc := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer canc()
+	err = conn.PingContext(ctx)
+	if err == nil {
+		err = db.migrate(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	db.conn = conn
+
+	return conn, nil
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// maxPresignedURLs bounds the urls kept by a presign cache. They are few,
+// three per employee with a photo, but keys of replaced photos pile up.
+const maxPresignedURLs = 10000
+
+type presignedURL struct {
+	url     string
+	expires time.Time
+	// validity is how long the url was signed for, which is shorter than
+	// asked when the credentials expired first.
+	validity time.Duration
+}
+
+// presignCache reuses presigned urls while at least a quarter of their
+// validity is left. Pages then link to the same url for a while, which the
+// browser can cache, and rendering the directory does not sign every photo
+// again.
+type presignCache struct {
+	PhotoStore
+
+	mu   sync.Mutex
+	urls map[string]presignedURL
+}
+
+// CachePresignedURLs wraps s so that presigned urls are reused until close
+// to their expiry.
+func CachePresignedURLs(s PhotoStore) PhotoStore {
+	return &presignCache{PhotoStore: s, urls: map[string]presignedURL{}}
+}
+
+func (c *presignCache) GeneratePresignedURL(objectKey string, expiry time.Duration) (string, time.Time, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	cached, exist := c.urls[objectKey]
+	c.mu.Unlock()
+	if exist && cached.expires.Sub(now) >= cached.validity/4 {
+		return cached.url, cached.expires, nil
+	}
+
+	url, expires, err := c.PhotoStore.GeneratePresignedURL(objectKey, expiry)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.urls) >= maxPresignedURLs {
+		c.prune(now)
+	}
+	c.urls[objectKey] = presignedURL{url: url, expires: expires, validity: expires.Sub(now)}
+
+	return url, expires, nil
+}
+
+// DeleteObject also forgets the url of the object, which no longer works.
+func (c *presignCache) DeleteObject(objectKey string) error {
+	c.mu.Lock()
+	delete(c.urls, objectKey)
+	c.mu.Unlock()
+
+	return c.PhotoStore.DeleteObject(objectKey)
+}
+
+// prune drops the expired urls, or every url when none has expired yet.
+func (c *presignCache) prune(now time.Time) {
+	for key, cached := range c.urls {
+		if !cached.expires.After(now) {
+			delete(c.urls, key)
+		}
+	}
+	if len(c.urls) >= maxPresignedURLs {
+		c.urls = map[string]presignedURL{}
+	}
+}
+
+func (c *presignCache) Close() error {
+	if closer, ok := c.PhotoStore.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
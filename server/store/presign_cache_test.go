@@ -0,0 +1,70 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signingStore signs urls numbered by call, valid for at most validity.
+type signingStore struct {
+	PhotoStore
+	validity time.Duration
+	signed   int
+	deleted  []string
+}
+
+func (s *signingStore) GeneratePresignedURL(objectKey string, expiry time.Duration) (string, time.Time, error) {
+	s.signed++
+	if s.validity < expiry {
+		expiry = s.validity
+	}
+	return objectKey + "?" + strconv.Itoa(s.signed), time.Now().Add(expiry), nil
+}
+
+func (s *signingStore) DeleteObject(objectKey string) error {
+	s.deleted = append(s.deleted, objectKey)
+	return nil
+}
+
+func TestPresignCacheReusesURLs(t *testing.T) {
+	signer := &signingStore{validity: time.Hour}
+	cache := CachePresignedURLs(signer)
+
+	first, _, _ := cache.GeneratePresignedURL("a.png", time.Hour)
+	again, _, _ := cache.GeneratePresignedURL("a.png", time.Hour)
+	if first != again || signer.signed != 1 {
+		t.Errorf("GeneratePresignedURL() = %q then %q after %d signatures, want one url", first, again, signer.signed)
+	}
+}
+
+func TestPresignCacheKeepsURLsUntilTheCredentialsExpire(t *testing.T) {
+	// the credentials last a second, far less than the week asked for
+	signer := &signingStore{validity: time.Second}
+	cache := CachePresignedURLs(signer)
+
+	_, expires, _ := cache.GeneratePresignedURL("a.png", 168*time.Hour)
+	if time.Until(expires) > time.Second {
+		t.Errorf("url expires in %s, want the second of the credentials", time.Until(expires))
+	}
+
+	time.Sleep(800 * time.Millisecond)
+	url, _, _ := cache.GeneratePresignedURL("a.png", 168*time.Hour)
+	if url != "a.png?2" {
+		t.Errorf("GeneratePresignedURL() = %q close to the expiry of the credentials, want a new url", url)
+	}
+}
+
+func TestPresignCacheForgetsDeletedObjects(t *testing.T) {
+	signer := &signingStore{validity: time.Hour}
+	cache := CachePresignedURLs(signer)
+
+	cache.GeneratePresignedURL("a.png", time.Hour)
+	if err := cache.DeleteObject("a.png"); err != nil {
+		t.Fatalf("DeleteObject() = %v", err)
+	}
+	url, _, _ := cache.GeneratePresignedURL("a.png", time.Hour)
+	if url != "a.png?2" || len(signer.deleted) != 1 {
+		t.Errorf("GeneratePresignedURL() = %q after the object was deleted, want a new url", url)
+	}
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type EmployeeStoreFactory func(dsn *url.URL) (EmployeeStore, error)
+
+type PhotoStoreFactory func(dsn *url.URL) (PhotoStore, error)
+
+var (
+	registryMu     sync.RWMutex
+	employeeStores = map[string]EmployeeStoreFactory{}
+	photoStores    = map[string]PhotoStoreFactory{}
+)
+
+// RegisterEmployeeStore makes an employee backend available under the given
+// URL scheme. Backends register themselves from an init function.
+func RegisterEmployeeStore(scheme string, factory EmployeeStoreFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exist := employeeStores[scheme]; exist {
+		panic(fmt.Sprintf("employee store '%s' registered twice", scheme))
+	}
+	employeeStores[scheme] = factory
+}
+
+// RegisterPhotoStore makes a photo backend available under the given URL scheme.
+func RegisterPhotoStore(scheme string, factory PhotoStoreFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exist := photoStores[scheme]; exist {
+		panic(fmt.Sprintf("photo store '%s' registered twice", scheme))
+	}
+	photoStores[scheme] = factory
+}
+
+func OpenEmployeeStore(dsn string) (EmployeeStore, error) {
+	u, err := parseStoreURL(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error to open employee store. Details: '%s'", err)
+	}
+
+	registryMu.RLock()
+	factory, exist := employeeStores[u.Scheme]
+	registryMu.RUnlock()
+	if !exist {
+		return nil, fmt.Errorf("unknown employee store '%s', expected one of: %s", u.Scheme, schemes(employeeStores))
+	}
+
+	return factory(u)
+}
+
+func OpenPhotoStore(dsn string) (PhotoStore, error) {
+	u, err := parseStoreURL(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error to open photo store. Details: '%s'", err)
+	}
+
+	registryMu.RLock()
+	factory, exist := photoStores[u.Scheme]
+	registryMu.RUnlock()
+	if !exist {
+		return nil, fmt.Errorf("unknown photo store '%s', expected one of: %s", u.Scheme, schemes(photoStores))
+	}
+
+	return factory(u)
+}
+
+// Backend names the backend of a store url, as used in metrics and logs.
+func Backend(dsn string) string {
+	u, err := parseStoreURL(dsn)
+	if err != nil {
+		return "unknown"
+	}
+
+	return u.Scheme
+}
+
+func parseStoreURL(dsn string) (*url.URL, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		// the error quotes the url, password included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("invalid store url: %s", urlErr.Err)
+		}
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("store url '%s' has no scheme", u.Redacted())
+	}
+
+	return u, nil
+}
+
+func schemes[F any](factories map[string]F) string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
@@ -5,6 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -13,57 +18,109 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
-	"github.com/moura1001/aws-employee-directory-application/server/utils"
 )
 
+func init() {
+	RegisterPhotoStore("s3", func(dsn *url.URL) (PhotoStore, error) {
+		if dsn.Host == "" {
+			return nil, fmt.Errorf("s3 store url '%s' has no bucket", dsn.Redacted())
+		}
+
+		return NewS3Store(dsn.Host, dsn.Path, dsn.Query().Get("region")), nil
+	})
+}
+
 type S3Store struct {
 	bucket string
+	prefix string
+	region string
 }
 
-func NewS3Store() S3Store {
+func NewS3Store(bucket, prefix, region string) S3Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
 	return S3Store{
-		bucket: utils.PHOTOS_BUCKET,
+		bucket: bucket,
+		prefix: prefix,
+		region: region,
 	}
 }
 
-func (s S3Store) getS3Client() (*s3.Client, error) {
+func (s S3Store) key(objectKey string) string {
+	return s.prefix + path.Clean("/" + objectKey)[1:]
+}
+
+func (s S3Store) getConfig() (aws.Config, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO(), func(opts *config.LoadOptions) error {
-		opts.Region = utils.AWS_DEFAULT_REGION
+		opts.Region = s.region
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error to get s3 connection. Details: '%s'", err)
+		return aws.Config{}, fmt.Errorf("error to get s3 connection. Details: '%s'", err)
+	}
+
+	return cfg, nil
+}
+
+func (s S3Store) getS3Client() (*s3.Client, error) {
+	cfg, err := s.getConfig()
+	if err != nil {
+		return nil, err
 	}
 
 	return s3.NewFromConfig(cfg), nil
 }
 
-func (s S3Store) GeneratePresignedURL(objectKey string) (string, error) {
+// GeneratePresignedURL signs a url valid for expiry, unless the credentials
+// expire sooner, as those of an instance or task role do after a few hours:
+// S3 refuses the url from then on, so it is signed only until then.
+func (s S3Store) GeneratePresignedURL(objectKey string, expiry time.Duration) (string, time.Time, error) {
 	errMsg := "error to get s3 object presigned url%s. Details: '%s'"
 
-	svc, err := s.getS3Client()
+	cfg, err := s.getConfig()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf(errMsg, "", err)
+	}
+
+	creds, err := cfg.Credentials.Retrieve(context.TODO())
 	if err != nil {
-		return "", fmt.Errorf(errMsg, "", err)
+		return "", time.Time{}, fmt.Errorf(errMsg, " Retrieve", err)
+	}
+	if left := time.Until(creds.Expires); creds.CanExpire && left < expiry {
+		expiry = left
 	}
+	if expiry < time.Second {
+		return "", time.Time{}, fmt.Errorf(errMsg, "", "credentials expired")
+	}
+	expires := time.Now().Add(expiry)
 
-	presignClient := s3.NewPresignClient(svc)
+	presignClient := s3.NewPresignClient(s3.NewFromConfig(cfg))
 
 	result, err := presignClient.PresignGetObject(
 		context.TODO(),
 		&s3.GetObjectInput{
 			Bucket: aws.String(s.bucket),
-			Key:    aws.String(objectKey),
+			Key:    aws.String(s.key(objectKey)),
+			// photo keys change with their content, so the browser may keep
+			// the object for as long as the url is valid
+			ResponseCacheControl: aws.String(fmt.Sprintf("private, max-age=%d", int(expiry.Seconds()))),
 		},
-		s3.WithPresignExpires(time.Minute*2),
+		s3.WithPresignExpires(expiry),
 	)
 	if err != nil {
-		return "", fmt.Errorf(errMsg, " PresignGetObject", err)
+		return "", time.Time{}, fmt.Errorf(errMsg, " PresignGetObject", err)
 	}
 
-	return result.URL, nil
+	return result.URL, expires, nil
 }
 
-func (s S3Store) UploadObject(objectKey string, content []byte) error {
+// UploadObject stores content with its type and an inline disposition, so
+// browsers following presigned urls show it as a picture and never sniff or
+// download it as something else.
+func (s S3Store) UploadObject(objectKey string, content []byte, contentType string) error {
 	errMsg := "error to upload s3 object%s. Details: '%s'"
 
 	svc, err := s.getS3Client()
@@ -76,9 +133,11 @@ func (s S3Store) UploadObject(objectKey string, content []byte) error {
 	uploader := manager.NewUploader(svc)
 
 	_, err = uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(objectKey),
-		Body:   contentBuffer,
+		Bucket:             aws.String(s.bucket),
+		Key:                aws.String(s.key(objectKey)),
+		Body:               contentBuffer,
+		ContentType:        aws.String(contentType),
+		ContentDisposition: aws.String(mime.FormatMediaType("inline", map[string]string{"filename": path.Base(objectKey)})),
 	})
 	if err != nil {
 		return fmt.Errorf(errMsg, " Upload", err)
@@ -87,33 +146,80 @@ func (s S3Store) UploadObject(objectKey string, content []byte) error {
 	return nil
 }
 
-func (s S3Store) IsHealthy() bool {
+func (s S3Store) ReadObject(objectKey string) ([]byte, error) {
+	errMsg := "error to read s3 object%s. Details: '%s'"
+
 	svc, err := s.getS3Client()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, "", err)
+	}
 
+	out, err := svc.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(objectKey)),
+	})
 	if err != nil {
-		return false
-	} else {
-		_, err = svc.HeadObject(context.TODO(), &s3.HeadObjectInput{
-			Bucket: aws.String(s.bucket),
-			Key:    aws.String("unknow"),
-		})
+		return nil, fmt.Errorf(errMsg, " GetObject", err)
+	}
+	defer out.Body.Close()
 
-		if err != nil {
+	content, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, " ReadAll", err)
+	}
+
+	return content, nil
+}
 
-			var nsk *types.NoSuchKey
-			if errors.As(err, &nsk) {
-				return true
-			}
+func (s S3Store) DeleteObject(objectKey string) error {
+	errMsg := "error to delete s3 object%s. Details: '%s'"
 
-			var apiErr smithy.APIError
-			if errors.As(err, &apiErr) {
-				return true
-			}
+	svc, err := s.getS3Client()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
 
-		} else {
-			return true
+	_, err = svc.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(objectKey)),
+	})
+	if err != nil {
+		return fmt.Errorf(errMsg, " DeleteObject", err)
+	}
+
+	return nil
+}
+
+func (s S3Store) Ping(ctx context.Context) error {
+	errMsg := "error to reach s3 bucket%s. Details: '%s'"
+
+	svc, err := s.getS3Client()
+	if err != nil {
+		return fmt.Errorf(errMsg, "", err)
+	}
+
+	_, err = svc.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key("unknow")),
+	})
+	if err != nil {
+		// the probe key doesn't exist, so only a not-found answer means
+		// the bucket is reachable and readable. S3 answers 403 instead of
+		// 404 for missing keys when s3:ListBucket isn't granted, which
+		// fails readiness just like throttling or a denied read would.
+		var nsk *types.NoSuchKey
+		var nf *types.NotFound
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			return nil
 		}
+
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey") {
+			return nil
+		}
+
+		return fmt.Errorf(errMsg, " HeadObject", err)
 	}
 
-	return false
+	return nil
 }
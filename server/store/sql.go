@@ -0,0 +1,263 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+)
+
+// column is a column added to the employee table after its first release.
+// Databases created by an older schema get it on start.
+type column struct {
+	name       string
+	definition string
+}
+
+// addColumns creates the columns missing from existing, the set of columns
+// the table has now.
+func addColumns(conn *sql.DB, existing map[string]bool, columns []column) error {
+	for _, c := range columns {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := conn.Exec("ALTER TABLE employee ADD COLUMN " + c.name + " " + c.definition); err != nil {
+			return fmt.Errorf("error to add column '%s'. Details: '%s'", c.name, err)
+		}
+	}
+	return nil
+}
+
+// profileRow holds the profile columns as scanned from a row, with the
+// nullable ones as sql.NullString. teams is read from the team_member table,
+// through the teamIdsColumn subquery scanned after the profile columns.
+type profileRow struct {
+	email      string
+	phones     string
+	department string
+	managerId  sql.NullString
+	startDate  sql.NullString
+	pronouns   string
+	timezone   string
+	bio        sql.NullString
+	handles    sql.NullString
+	custom     sql.NullString
+	teams      sql.NullString
+}
+
+// dest returns the scan destinations in the order of profileColumns,
+// followed by the team ids.
+func (p *profileRow) dest() []interface{} {
+	return []interface{}{&p.email, &p.phones, &p.department, &p.managerId, &p.startDate, &p.pronouns, &p.timezone, &p.bio, &p.handles, &p.custom, &p.teams}
+}
+
+func (p *profileRow) profile() model.Profile {
+	return model.Profile{
+		Email:      p.email,
+		Phones:     splitList(p.phones),
+		Department: p.department,
+		ManagerId:  p.managerId.String,
+		StartDate:  p.startDate.String,
+		Pronouns:   p.pronouns,
+		Timezone:   p.timezone,
+		Bio:        p.bio.String,
+		Handles:    decodeHandles(p.handles.String),
+		Custom:     decodeCustom(p.custom.String),
+		Teams:      splitList(p.teams.String),
+	}
+}
+
+// profileArgs returns the values of profile in the order of profileColumns,
+// with empty manager, start date, handles and custom fields as NULL.
+func profileArgs(profile model.Profile) []interface{} {
+	return []interface{}{
+		profile.Email,
+		strings.Join(profile.Phones, ","),
+		profile.Department,
+		nullable(profile.ManagerId),
+		nullable(profile.StartDate),
+		profile.Pronouns,
+		profile.Timezone,
+		profile.Bio,
+		nullable(encodeHandles(profile.Handles)),
+		nullable(encodeCustom(profile.Custom)),
+	}
+}
+
+// profileColumns are the profile columns shared by the SQL stores, in the
+// order used by profileRow and profileArgs.
+const profileColumns = "email, phones, department, manager_id, start_date, pronouns, timezone, bio, handles, custom_fields"
+
+// teamIdsColumn lists the teams of each employee row as comma separated
+// ids. MySQL and SQLite share it; PostgreSQL has string_agg instead.
+const teamIdsColumn = "(SELECT GROUP_CONCAT(team_id) FROM team_member WHERE team_member.employee_id=employee.id)"
+
+// setTeams replaces the memberships of an employee within tx, the
+// transaction that writes the employee. The statements are given by the
+// store, as the placeholders differ.
+func setTeams(tx *sql.Tx, deleteQuery, insertQuery string, employeeId interface{}, teams []string) error {
+	if _, err := tx.Exec(deleteQuery, employeeId); err != nil {
+		return err
+	}
+	for _, teamId := range teams {
+		if _, err := tx.Exec(insertQuery, teamId, employeeId); err != nil {
+			return fmt.Errorf("error to add employee to team '%s'. Details: '%s'", teamId, err)
+		}
+	}
+
+	return nil
+}
+
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+func encodeHandles(handles map[string]string) string {
+	if len(handles) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(handles)
+	return string(b)
+}
+
+func decodeHandles(value string) map[string]string {
+	handles := map[string]string{}
+	if value != "" {
+		json.Unmarshal([]byte(value), &handles)
+	}
+	return handles
+}
+
+func encodeCustom(custom map[string][]string) string {
+	if len(custom) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(custom)
+	return string(b)
+}
+
+func decodeCustom(value string) map[string][]string {
+	custom := map[string][]string{}
+	if value != "" {
+		json.Unmarshal([]byte(value), &custom)
+	}
+	return custom
+}
+
+// customFieldColumns are the columns of the custom_field table, in the
+// order used by scanCustomFields and customFieldArgs. key and type are
+// reserved words in some databases, hence the prefix.
+const customFieldColumns = "field_key, label, field_type, options, required, visibility, position"
+
+func scanCustomFields(rows *sql.Rows) ([]model.CustomField, error) {
+	defer rows.Close()
+
+	fields := []model.CustomField{}
+	for rows.Next() {
+		var field model.CustomField
+		var options sql.NullString
+		err := rows.Scan(&field.Key, &field.Label, &field.Type, &options, &field.Required, &field.Visibility, &field.Position)
+		if err != nil {
+			return nil, err
+		}
+		if options.String != "" {
+			json.Unmarshal([]byte(options.String), &field.Options)
+		}
+		fields = append(fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	model.SortCustomFields(fields)
+	return fields, nil
+}
+
+func customFieldArgs(field model.CustomField) []interface{} {
+	var options interface{}
+	if len(field.Options) > 0 {
+		b, _ := json.Marshal(field.Options)
+		options = string(b)
+	}
+	return []interface{}{field.Key, field.Label, field.Type, options, field.Required, field.Visibility, field.Position}
+}
+
+// teamColumns are the columns of the team table, in the order used by
+// scanTeams and teamArgs.
+const teamColumns = "id, name, kind, description, lead_id"
+
+func scanTeams(rows *sql.Rows) ([]*model.Team, error) {
+	defer rows.Close()
+
+	teams := []*model.Team{}
+	for rows.Next() {
+		team := &model.Team{}
+		var description, leadId sql.NullString
+		if err := rows.Scan(&team.Id, &team.Name, &team.Kind, &description, &leadId); err != nil {
+			return nil, err
+		}
+		team.Description = description.String
+		team.LeadId = leadId.String
+		teams = append(teams, team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// teamArgs returns the values of team for the columns after the id.
+func teamArgs(team model.Team) []interface{} {
+	return []interface{}{team.Name, team.Kind, team.Description, nullable(team.LeadId)}
+}
+
+// officeColumns are the columns of the office table, in the order used by
+// scanOffices and officeArgs.
+const officeColumns = "id, name, address, timezone, latitude, longitude"
+
+func scanOffices(rows *sql.Rows) ([]*model.Office, error) {
+	defer rows.Close()
+
+	offices := []*model.Office{}
+	for rows.Next() {
+		office := &model.Office{}
+		var address sql.NullString
+		var latitude, longitude sql.NullFloat64
+		if err := rows.Scan(&office.Id, &office.Name, &address, &office.Timezone, &latitude, &longitude); err != nil {
+			return nil, err
+		}
+		office.Address = address.String
+		if latitude.Valid && longitude.Valid {
+			office.Latitude, office.Longitude = &latitude.Float64, &longitude.Float64
+		}
+		offices = append(offices, office)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return offices, nil
+}
+
+// officeArgs returns the values of office for the columns after the id,
+// with unknown coordinates as NULL.
+func officeArgs(office model.Office) []interface{} {
+	var latitude, longitude interface{}
+	if office.Latitude != nil && office.Longitude != nil {
+		latitude, longitude = *office.Latitude, *office.Longitude
+	}
+	return []interface{}{office.Name, office.Address, office.Timezone, latitude, longitude}
+}
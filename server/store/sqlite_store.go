@@ -0,0 +1,676 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	RegisterEmployeeStore("sqlite", func(dsn *url.URL) (EmployeeStore, error) {
+		// sqlite://employees.db is relative, sqlite:///var/lib/employees.db absolute
+		path := dsn.Host + dsn.Path
+		if dsn.Opaque != "" {
+			path = dsn.Opaque
+		}
+		if path == "" {
+			return nil, fmt.Errorf("sqlite store url '%s' has no file path", dsn.Redacted())
+		}
+
+		return NewSqliteStore(path), nil
+	})
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS employee (
+  id integer not null primary key autoincrement,
+  object_key varchar(80) not null default '',
+  full_name varchar(200) not null,
+  location varchar(200) not null,
+  job_title varchar(200) not null,
+  badges varchar(200) not null,
+  created_datetime datetime default current_timestamp
+);
+CREATE INDEX IF NOT EXISTS idx_employee_location ON employee (location);
+CREATE INDEX IF NOT EXISTS idx_employee_job_title ON employee (job_title);
+CREATE TABLE IF NOT EXISTS custom_field (
+  field_key varchar(64) not null primary key,
+  label varchar(200) not null,
+  field_type varchar(20) not null,
+  options text,
+  required boolean not null default false,
+  visibility varchar(20) not null default 'public',
+  position integer not null default 0
+);
+CREATE TABLE IF NOT EXISTS team (
+  id integer not null primary key autoincrement,
+  name varchar(200) not null,
+  kind varchar(20) not null default 'team',
+  description text,
+  lead_id integer references employee(id) on delete set null
+);
+CREATE TABLE IF NOT EXISTS team_member (
+  team_id integer not null references team(id) on delete cascade,
+  employee_id integer not null references employee(id) on delete cascade,
+  primary key (team_id, employee_id)
+);
+CREATE INDEX IF NOT EXISTS idx_team_member_employee ON team_member (employee_id);
+CREATE TABLE IF NOT EXISTS office (
+  id integer not null primary key autoincrement,
+  name varchar(200) not null unique,
+  address text,
+  timezone varchar(64) not null default '',
+  latitude real,
+  longitude real
+);
+`
+
+const (
+	sqliteDeleteTeams = "DELETE FROM team_member WHERE employee_id=?"
+	sqliteInsertTeam  = "INSERT INTO team_member(team_id, employee_id) VALUES(?,?)"
+)
+
+// sqliteColumns are added to databases created before the profile fields.
+// SQLite has no ADD COLUMN IF NOT EXISTS, so they are created one by one
+// when table_info does not list them.
+var sqliteColumns = []column{
+	{"email", "varchar(254) not null default ''"},
+	{"phones", "varchar(200) not null default ''"},
+	{"department", "varchar(200) not null default ''"},
+	{"manager_id", "integer references employee(id) on delete set null"},
+	{"start_date", "date"},
+	{"pronouns", "varchar(40) not null default ''"},
+	{"timezone", "varchar(64) not null default ''"},
+	{"bio", "text"},
+	{"handles", "text"},
+	{"custom_fields", "text"},
+}
+
+// SqliteStore keeps the whole directory in a single database file. The
+// connection is shared and limited to one writer, since SQLite serializes
+// writes anyway and concurrent connections would only fail with SQLITE_BUSY.
+type SqliteStore struct {
+	path string
+	mu   sync.Mutex
+	conn *sql.DB
+}
+
+func NewSqliteStore(path string) *SqliteStore {
+	return &SqliteStore{
+		path: path,
+	}
+}
+
+func (db *SqliteStore) ListEmployees() ([]*model.Employee, error) {
+	return db.listEmployees("")
+}
+
+func (db *SqliteStore) ListEmployeesByLocation(location string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE location=?", location)
+}
+
+func (db *SqliteStore) ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE job_title=?", jobTitle)
+}
+
+func (db *SqliteStore) ListEmployeesByBadge(badge string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE instr(',' || badges || ',', ',' || ? || ',') > 0", badge)
+}
+
+func (db *SqliteStore) ListEmployeesByTeam(teamId string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE id IN (SELECT employee_id FROM team_member WHERE team_id=?)", teamId)
+}
+
+func (db *SqliteStore) ListEmployeesByManager(managerId string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE manager_id=?", managerId)
+}
+
+// ListSubordinates uses the same recursive query as the MySQL store.
+func (db *SqliteStore) ListSubordinates(employeeId string) ([]*model.Employee, error) {
+	return db.listEmployees("WHERE id IN (WITH RECURSIVE subtree (id) AS (SELECT id FROM employee WHERE manager_id=? UNION SELECT e.id FROM employee e JOIN subtree s ON e.manager_id=s.id) SELECT id FROM subtree)", employeeId)
+}
+
+func (db *SqliteStore) listEmployees(where string, args ...interface{}) ([]*model.Employee, error) {
+	errMsg := "error to get employee list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		query := "SELECT id, object_key, full_name, location, job_title, badges, " + profileColumns + ", " + teamIdsColumn + " FROM employee " + where + " ORDER BY id DESC"
+		selEmp, err := conn.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		defer selEmp.Close()
+
+		res := []*model.Employee{}
+		for selEmp.Next() {
+			emp := &model.Employee{Photo: new(model.Photo)}
+			var b string
+			var p profileRow
+			err = selEmp.Scan(append([]interface{}{&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &(emp.JobTitle), &b}, p.dest()...)...)
+			if err != nil {
+				return nil, fmt.Errorf(errMsg, err)
+			}
+			emp.Profile = p.profile()
+			emp.Badges = splitBadges(b)
+
+			res = append(res, emp)
+		}
+		if err := selEmp.Err(); err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return res, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) LoadEmployee(employeeId string) (*model.Employee, error) {
+	errMsg := "error to get employee data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		emp := &model.Employee{Photo: new(model.Photo)}
+		var b string
+		var p profileRow
+		err = conn.QueryRow("SELECT id, object_key, full_name, location, job_title, badges, "+profileColumns+", "+teamIdsColumn+" FROM employee WHERE id=?", employeeId).
+			Scan(append([]interface{}{&(emp.Id), &(emp.Photo.ObjectKey), &(emp.FullName), &(emp.Location), &(emp.JobTitle), &b}, p.dest()...)...)
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		emp.Badges = splitBadges(b)
+		emp.Profile = p.profile()
+
+		return emp, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error) {
+	errMsg := "error to insert employee data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		// the employee and their teams are written together
+		tx, err := conn.Begin()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+		defer tx.Rollback()
+
+		query := "INSERT INTO employee(object_key, full_name, location, job_title, badges, " + profileColumns + ") VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+
+		res, err := tx.Exec(query, append([]interface{}{objectKey, fullName, location, jobTitle, strings.Join(badges, ",")}, profileArgs(profile)...)...)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		empId, err := res.LastInsertId()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, fmt.Errorf("failed to get last inserted id: '%s'", err))
+		}
+
+		err = setTeams(tx, sqliteDeleteTeams, sqliteInsertTeam, empId, profile.Teams)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		return strconv.FormatInt(empId, 10), nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error {
+	errMsg := "error to update employee data. Details: '%s'"
+
+	empId, err := strconv.ParseInt(employeeId, 10, 32)
+	if err != nil {
+		return fmt.Errorf("employee '%s' does not exist", employeeId)
+	}
+
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		// the employee and their teams are written together
+		tx, err := conn.Begin()
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+		defer tx.Rollback()
+
+		query := "UPDATE employee SET object_key=?, full_name=?, location=?, job_title=?, badges=?, " +
+			"email=?, phones=?, department=?, manager_id=?, start_date=?, pronouns=?, timezone=?, bio=?, handles=?, custom_fields=? WHERE id=?"
+
+		args := append([]interface{}{objectKey, fullName, location, jobTitle, strings.Join(badges, ",")}, profileArgs(profile)...)
+		res, err := tx.Exec(query, append(args, empId)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("employee '%s' does not exist", employeeId)
+		}
+
+		err = setTeams(tx, sqliteDeleteTeams, sqliteInsertTeam, empId, profile.Teams)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) DeleteEmployee(employeeId string) error {
+	errMsg := "error to delete employee data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM employee WHERE id=?", employeeId)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) ListCustomFields() ([]model.CustomField, error) {
+	errMsg := "error to get custom fields. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + customFieldColumns + " FROM custom_field")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		fields, err := scanCustomFields(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return fields, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) SaveCustomField(field model.CustomField) error {
+	errMsg := "error to save custom field. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		query := "INSERT INTO custom_field(" + customFieldColumns + ") VALUES(?,?,?,?,?,?,?) " +
+			"ON CONFLICT (field_key) DO UPDATE SET label=excluded.label, field_type=excluded.field_type, options=excluded.options, " +
+			"required=excluded.required, visibility=excluded.visibility, position=excluded.position"
+
+		_, err = conn.Exec(query, customFieldArgs(field)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) DeleteCustomField(key string) error {
+	errMsg := "error to delete custom field. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM custom_field WHERE field_key=?", key)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) ListTeams() ([]*model.Team, error) {
+	errMsg := "error to get team list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + teamColumns + " FROM team ORDER BY name")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		teams, err := scanTeams(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return teams, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) LoadTeam(teamId string) (*model.Team, error) {
+	errMsg := "error to get team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT "+teamColumns+" FROM team WHERE id=?", teamId)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		teams, err := scanTeams(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		if len(teams) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return teams[0], nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) AddTeam(team model.Team) (string, error) {
+	errMsg := "error to insert team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("INSERT INTO team(name, kind, description, lead_id) VALUES(?,?,?,?)", teamArgs(team)...)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		teamId, err := res.LastInsertId()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, fmt.Errorf("failed to get last inserted id: '%s'", err))
+		}
+
+		return strconv.FormatInt(teamId, 10), nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) UpdateTeam(team model.Team) error {
+	errMsg := "error to update team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("UPDATE team SET name=?, kind=?, description=?, lead_id=? WHERE id=?", append(teamArgs(team), team.Id)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("team '%s' does not exist", team.Id)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+// DeleteTeam relies on the foreign_keys pragma of the connection, without
+// which team_member would not cascade.
+func (db *SqliteStore) DeleteTeam(teamId string) error {
+	errMsg := "error to delete team data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM team WHERE id=?", teamId)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) ListOffices() ([]*model.Office, error) {
+	errMsg := "error to get office list. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT " + officeColumns + " FROM office ORDER BY name")
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		offices, err := scanOffices(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		return offices, nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) LoadOffice(officeId string) (*model.Office, error) {
+	errMsg := "error to get office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		rows, err := conn.Query("SELECT "+officeColumns+" FROM office WHERE id=?", officeId)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		offices, err := scanOffices(rows)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		if len(offices) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return offices[0], nil
+
+	} else {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) AddOffice(office model.Office) (string, error) {
+	errMsg := "error to insert office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("INSERT INTO office(name, address, timezone, latitude, longitude) VALUES(?,?,?,?,?)", officeArgs(office)...)
+		if err != nil {
+			return "", fmt.Errorf(errMsg, err)
+		}
+
+		officeId, err := res.LastInsertId()
+		if err != nil {
+			return "", fmt.Errorf(errMsg, fmt.Errorf("failed to get last inserted id: '%s'", err))
+		}
+
+		return strconv.FormatInt(officeId, 10), nil
+
+	} else {
+		return "", fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) UpdateOffice(office model.Office) error {
+	errMsg := "error to update office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		res, err := conn.Exec("UPDATE office SET name=?, address=?, timezone=?, latitude=?, longitude=? WHERE id=?", append(officeArgs(office), office.Id)...)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("office '%s' does not exist", office.Id)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) DeleteOffice(officeId string) error {
+	errMsg := "error to delete office data. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("DELETE FROM office WHERE id=?", officeId)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) RenameLocation(from, to string) error {
+	errMsg := "error to rename location. Details: '%s'"
+	conn, err := db.getDatabaseConnection()
+
+	if err == nil {
+		_, err = conn.Exec("UPDATE employee SET location=? WHERE location=?", to, from)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		return nil
+
+	} else {
+		return fmt.Errorf(errMsg, err)
+	}
+}
+
+func (db *SqliteStore) Ping(ctx context.Context) error {
+	conn, err := db.getDatabaseConnection()
+	if err != nil {
+		return err
+	}
+
+	return conn.PingContext(ctx)
+}
+
+func (db *SqliteStore) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn == nil {
+		return nil
+	}
+
+	err := db.conn.Close()
+	db.conn = nil
+
+	return err
+}
+
+func (db *SqliteStore) getDatabaseConnection() (*sql.DB, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.conn != nil {
+		return db.conn, nil
+	}
+
+	conn, err := sql.Open("sqlite", "file:"+db.path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("error to open sqlite database '%s'. Details: '%s'", db.path, err)
+	}
+	conn.SetMaxOpenConns(1)
+
+	err = migrateSqlite(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error to migrate sqlite schema. Details: '%s'", err)
+	}
+
+	db.conn = conn
+
+	return conn, nil
+}
+
+func migrateSqlite(conn *sql.DB) error {
+	if _, err := conn.Exec(sqliteSchema); err != nil {
+		return err
+	}
+
+	rows, err := conn.Query("SELECT name FROM pragma_table_info('employee')")
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			existing[name] = true
+		}
+	}
+	rows.Close()
+
+	return addColumns(conn, existing, sqliteColumns)
+}
+
+func splitBadges(b string) []string {
+	if len(b) > 0 {
+		return strings.Split(b, ",")
+	}
+	return []string{}
+}
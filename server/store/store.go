@@ -1,14 +1,78 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/moura1001/aws-employee-directory-application/server/model"
 )
 
+// ErrNotFound is returned by LoadEmployee, LoadTeam and LoadOffice when
+// there is no record with the id given.
+var ErrNotFound = errors.New("not found")
+
 type EmployeeStore interface {
 	ListEmployees() ([]*model.Employee, error)
+	ListEmployeesByLocation(location string) ([]*model.Employee, error)
+	ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error)
+	ListEmployeesByBadge(badge string) ([]*model.Employee, error)
+	ListEmployeesByTeam(teamId string) ([]*model.Employee, error)
+	// ListEmployeesByManager returns the direct reports of managerId only.
+	ListEmployeesByManager(managerId string) ([]*model.Employee, error)
+	// LoadEmployee returns ErrNotFound when the employee does not exist.
 	LoadEmployee(employeeId string) (*model.Employee, error)
-	AddEmployee(objectKey, fullName, location, jobTitle string, badges []string) (string, error)
-	UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string) error
+	// ListSubordinates returns everyone who reports to employeeId, directly
+	// or through other managers, in no particular order.
+	ListSubordinates(employeeId string) ([]*model.Employee, error)
+	AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error)
+	UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error
 	DeleteEmployee(employeeId string) error
-	IsHealthy() bool
+	// ListCustomFields returns the fields defined by administrators, sorted
+	// by position. SaveCustomField creates or replaces a field by key;
+	// deleting one keeps the values already stored for employees.
+	ListCustomFields() ([]model.CustomField, error)
+	SaveCustomField(field model.CustomField) error
+	DeleteCustomField(key string) error
+	// ListTeams returns the departments and teams sorted by name. LoadTeam
+	// returns ErrNotFound when the team does not exist, and deleting one
+	// removes it from the profile of its members.
+	ListTeams() ([]*model.Team, error)
+	LoadTeam(teamId string) (*model.Team, error)
+	AddTeam(team model.Team) (string, error)
+	UpdateTeam(team model.Team) error
+	DeleteTeam(teamId string) error
+	// ListOffices returns the offices sorted by name, and LoadOffice
+	// ErrNotFound when the office does not exist. Employees refer to an office by name,
+	// through their location, which RenameLocation changes for everyone at
+	// once.
+	ListOffices() ([]*model.Office, error)
+	LoadOffice(officeId string) (*model.Office, error)
+	AddOffice(office model.Office) (string, error)
+	UpdateOffice(office model.Office) error
+	DeleteOffice(officeId string) error
+	RenameLocation(from, to string) error
+	// Ping checks that the store answers, giving up when ctx is done.
+	Ping(ctx context.Context) error
+}
+
+// Migrator is implemented by the stores that bring data written by older
+// versions up to date after they open, while already serving requests.
+type Migrator interface {
+	Migrate() error
+}
+
+type PhotoStore interface {
+	// GeneratePresignedURL returns a url the browser can load the object
+	// from, and the time it stops working: after expiry, or sooner if the
+	// credentials that signed it expire first.
+	GeneratePresignedURL(objectKey string, expiry time.Duration) (string, time.Time, error)
+	// UploadObject stores content under objectKey, to be served as
+	// contentType.
+	UploadObject(objectKey string, content []byte, contentType string) error
+	ReadObject(objectKey string) ([]byte, error)
+	// DeleteObject removes the object. Removing one that does not exist
+	// is not an error.
+	DeleteObject(objectKey string) error
+	Ping(ctx context.Context) error
 }
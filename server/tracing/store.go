@@ -0,0 +1,281 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/moura1001/aws-employee-directory-application/server/model"
+	"github.com/moura1001/aws-employee-directory-application/server/store"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type employeeStore struct {
+	store.EmployeeStore
+	ctx     context.Context
+	backend string
+}
+
+// EmployeeStore binds s to the request context ctx, so that each call
+// becomes a child span of the request span. The stores themselves don't take
+// a context, hence one wrapper per request.
+func EmployeeStore(ctx context.Context, backend string, s store.EmployeeStore) store.EmployeeStore {
+	return &employeeStore{EmployeeStore: s, ctx: ctx, backend: backend}
+}
+
+func (s *employeeStore) start(operation string, attrs ...attribute.KeyValue) func(err error) {
+	attrs = append(attrs, attribute.String("store.backend", s.backend))
+	_, span := Start(s.ctx, "EmployeeStore."+operation, attrs...)
+	return func(err error) {
+		if errors.Is(err, store.ErrNotFound) {
+			err = nil
+		}
+		End(span, err)
+	}
+}
+
+func (s *employeeStore) ListEmployees() ([]*model.Employee, error) {
+	end := s.start("ListEmployees")
+	emps, err := s.EmployeeStore.ListEmployees()
+	end(err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByLocation(location string) ([]*model.Employee, error) {
+	end := s.start("ListEmployeesByLocation")
+	emps, err := s.EmployeeStore.ListEmployeesByLocation(location)
+	end(err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByJobTitle(jobTitle string) ([]*model.Employee, error) {
+	end := s.start("ListEmployeesByJobTitle")
+	emps, err := s.EmployeeStore.ListEmployeesByJobTitle(jobTitle)
+	end(err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByBadge(badge string) ([]*model.Employee, error) {
+	end := s.start("ListEmployeesByBadge", attribute.String("employee.badge", badge))
+	emps, err := s.EmployeeStore.ListEmployeesByBadge(badge)
+	end(err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByTeam(teamId string) ([]*model.Employee, error) {
+	end := s.start("ListEmployeesByTeam", attribute.String("team.id", teamId))
+	emps, err := s.EmployeeStore.ListEmployeesByTeam(teamId)
+	end(err)
+	return emps, err
+}
+
+func (s *employeeStore) ListEmployeesByManager(managerId string) ([]*model.Employee, error) {
+	end := s.start("ListEmployeesByManager", attribute.String("employee.id", managerId))
+	emps, err := s.EmployeeStore.ListEmployeesByManager(managerId)
+	end(err)
+	return emps, err
+}
+
+func (s *employeeStore) LoadEmployee(employeeId string) (*model.Employee, error) {
+	end := s.start("LoadEmployee", attribute.String("employee.id", employeeId))
+	emp, err := s.EmployeeStore.LoadEmployee(employeeId)
+	end(err)
+	return emp, err
+}
+
+func (s *employeeStore) ListSubordinates(employeeId string) ([]*model.Employee, error) {
+	end := s.start("ListSubordinates", attribute.String("employee.id", employeeId))
+	emps, err := s.EmployeeStore.ListSubordinates(employeeId)
+	end(err)
+	return emps, err
+}
+
+func (s *employeeStore) AddEmployee(objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) (string, error) {
+	end := s.start("AddEmployee")
+	id, err := s.EmployeeStore.AddEmployee(objectKey, fullName, location, jobTitle, badges, profile)
+	end(err)
+	return id, err
+}
+
+func (s *employeeStore) UpdateEmployee(employeeId string, objectKey, fullName, location, jobTitle string, badges []string, profile model.Profile) error {
+	end := s.start("UpdateEmployee", attribute.String("employee.id", employeeId))
+	err := s.EmployeeStore.UpdateEmployee(employeeId, objectKey, fullName, location, jobTitle, badges, profile)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) DeleteEmployee(employeeId string) error {
+	end := s.start("DeleteEmployee", attribute.String("employee.id", employeeId))
+	err := s.EmployeeStore.DeleteEmployee(employeeId)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) ListCustomFields() ([]model.CustomField, error) {
+	end := s.start("ListCustomFields")
+	fields, err := s.EmployeeStore.ListCustomFields()
+	end(err)
+	return fields, err
+}
+
+func (s *employeeStore) SaveCustomField(field model.CustomField) error {
+	end := s.start("SaveCustomField", attribute.String("custom_field.key", field.Key))
+	err := s.EmployeeStore.SaveCustomField(field)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) DeleteCustomField(key string) error {
+	end := s.start("DeleteCustomField", attribute.String("custom_field.key", key))
+	err := s.EmployeeStore.DeleteCustomField(key)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) ListTeams() ([]*model.Team, error) {
+	end := s.start("ListTeams")
+	teams, err := s.EmployeeStore.ListTeams()
+	end(err)
+	return teams, err
+}
+
+func (s *employeeStore) LoadTeam(teamId string) (*model.Team, error) {
+	end := s.start("LoadTeam", attribute.String("team.id", teamId))
+	team, err := s.EmployeeStore.LoadTeam(teamId)
+	end(err)
+	return team, err
+}
+
+func (s *employeeStore) AddTeam(team model.Team) (string, error) {
+	end := s.start("AddTeam")
+	id, err := s.EmployeeStore.AddTeam(team)
+	end(err)
+	return id, err
+}
+
+func (s *employeeStore) UpdateTeam(team model.Team) error {
+	end := s.start("UpdateTeam", attribute.String("team.id", team.Id))
+	err := s.EmployeeStore.UpdateTeam(team)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) DeleteTeam(teamId string) error {
+	end := s.start("DeleteTeam", attribute.String("team.id", teamId))
+	err := s.EmployeeStore.DeleteTeam(teamId)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) ListOffices() ([]*model.Office, error) {
+	end := s.start("ListOffices")
+	offices, err := s.EmployeeStore.ListOffices()
+	end(err)
+	return offices, err
+}
+
+func (s *employeeStore) LoadOffice(officeId string) (*model.Office, error) {
+	end := s.start("LoadOffice", attribute.String("office.id", officeId))
+	office, err := s.EmployeeStore.LoadOffice(officeId)
+	end(err)
+	return office, err
+}
+
+func (s *employeeStore) AddOffice(office model.Office) (string, error) {
+	end := s.start("AddOffice")
+	id, err := s.EmployeeStore.AddOffice(office)
+	end(err)
+	return id, err
+}
+
+func (s *employeeStore) UpdateOffice(office model.Office) error {
+	end := s.start("UpdateOffice", attribute.String("office.id", office.Id))
+	err := s.EmployeeStore.UpdateOffice(office)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) DeleteOffice(officeId string) error {
+	end := s.start("DeleteOffice", attribute.String("office.id", officeId))
+	err := s.EmployeeStore.DeleteOffice(officeId)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) RenameLocation(from, to string) error {
+	end := s.start("RenameLocation")
+	err := s.EmployeeStore.RenameLocation(from, to)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) Ping(ctx context.Context) error {
+	end := s.start("Ping")
+	err := s.EmployeeStore.Ping(ctx)
+	end(err)
+	return err
+}
+
+func (s *employeeStore) Close() error {
+	if closer, ok := s.EmployeeStore.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type photoStore struct {
+	store.PhotoStore
+	ctx     context.Context
+	backend string
+}
+
+// PhotoStore binds s to the request context ctx, like EmployeeStore.
+func PhotoStore(ctx context.Context, backend string, s store.PhotoStore) store.PhotoStore {
+	return &photoStore{PhotoStore: s, ctx: ctx, backend: backend}
+}
+
+func (s *photoStore) start(operation, objectKey string) func(err error) {
+	_, span := Start(s.ctx, "PhotoStore."+operation,
+		attribute.String("store.backend", s.backend),
+		attribute.String("photo.object_key", objectKey),
+	)
+	return func(err error) {
+		End(span, err)
+	}
+}
+
+func (s *photoStore) GeneratePresignedURL(objectKey string, expiry time.Duration) (string, time.Time, error) {
+	end := s.start("GeneratePresignedURL", objectKey)
+	url, expires, err := s.PhotoStore.GeneratePresignedURL(objectKey, expiry)
+	end(err)
+	return url, expires, err
+}
+
+func (s *photoStore) UploadObject(objectKey string, content []byte, contentType string) error {
+	end := s.start("UploadObject", objectKey)
+	err := s.PhotoStore.UploadObject(objectKey, content, contentType)
+	end(err)
+	return err
+}
+
+func (s *photoStore) ReadObject(objectKey string) ([]byte, error) {
+	end := s.start("ReadObject", objectKey)
+	content, err := s.PhotoStore.ReadObject(objectKey)
+	end(err)
+	return content, err
+}
+
+func (s *photoStore) DeleteObject(objectKey string) error {
+	end := s.start("DeleteObject", objectKey)
+	err := s.PhotoStore.DeleteObject(objectKey)
+	end(err)
+	return err
+}
+
+func (s *photoStore) Close() error {
+	if closer, ok := s.PhotoStore.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// AvatarSVG draws initials in white on a background of color, at width x
+// height. Being vector, the same picture looks sharp at every size the
+// pages show it.
+func AvatarSVG(initials, color string, width, height int) []byte {
+	var text bytes.Buffer
+	xml.EscapeText(&text, []byte(initials))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s">`,
+		width, height, width, height, text.String())
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`, width, height, color)
+	fmt.Fprintf(&b, `<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" fill="#ffffff" font-family="Helvetica, Arial, sans-serif" font-size="%d" font-weight="bold">%s</text>`,
+		width*2/5, text.String())
+	b.WriteString(`</svg>`)
+
+	return b.Bytes()
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a TLS key pair from disk and picks up renewed files
+// without a restart. The files are checked at most once per checkInterval.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu            sync.Mutex
+	cert          *tls.Certificate
+	modTime       time.Time
+	lastCheck     time.Time
+	checkInterval time.Duration
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: 10 * time.Second,
+	}
+
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.lastCheck) >= c.checkInterval {
+		// keep serving the previous certificate if the new files are
+		// half-written or invalid
+		c.reload()
+	}
+
+	return c.cert, nil
+}
+
+func (c *CertReloader) reload() error {
+	c.lastCheck = time.Now()
+
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+	if c.cert != nil && !modTime.After(c.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error to load tls certificate. Details: '%s'", err)
+	}
+
+	c.cert = &cert
+	c.modTime = modTime
+
+	return nil
+}
+
+func (c *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, fmt.Errorf("error to stat tls file. Details: '%s'", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
@@ -1,14 +0,0 @@
-package utils
-
-var PHOTOS_BUCKET = ""
-var CSRF_SECRET = ""
-var SESSION_KEY = ""
-
-var DATABASE_HOST = ""
-var DATABASE_USER = ""
-var DATABASE_PASSWORD = ""
-var DATABASE_DB_NAME = ""
-
-var DYNAMO_MODE = ""
-
-var AWS_DEFAULT_REGION = ""
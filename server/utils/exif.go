@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// jpegOrientation returns the EXIF orientation of the JPEG in file, from 1
+// to 8, or 1 when it has none. Cameras store the picture as the sensor read
+// it and tell viewers how to turn it with this tag.
+func jpegOrientation(file []byte) int {
+	if len(file) < 2 || file[0] != 0xff || file[1] != 0xd8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(file); {
+		if file[i] != 0xff {
+			return 1
+		}
+		marker := file[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			// the metadata comes before the image data
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(file[i+2:]))
+		if length < 2 || i+2+length > len(file) {
+			return 1
+		}
+		segment := file[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first directory of
+// the TIFF structure EXIF data is kept in.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// tag 0x0112 is the orientation, a single SHORT
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+
+	return 1
+}
+
+// swapsSides tells whether orientation turns the picture by a quarter, so
+// its width is shown as the height.
+func swapsSides(orientation int) bool {
+	return orientation >= 5
+}
+
+// orient turns and flips src as orientation tells viewers to, so the
+// picture shows the same once the tag is left out.
+func orient(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	in := toNRGBA(src)
+	w, h := in.Rect.Dx(), in.Rect.Dy()
+	dw, dh := w, h
+	if swapsSides(orientation) {
+		dw, dh = h, w
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // upside down and mirrored
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // turned a quarter clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // turned a quarter counterclockwise
+				dx, dy = y, w-1-x
+			}
+			copy(out.Pix[out.PixOffset(dx, dy):][:4], in.Pix[in.PixOffset(x, y):][:4])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifSegment returns an APP1 segment whose EXIF data holds only the
+// orientation, in the given byte order.
+func exifSegment(order binary.ByteOrder, orientation int) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	data := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(data)+2))
+	return append(segment, data...)
+}
+
+// withExif returns the JPEG with the segment added after its start marker,
+// where cameras put it.
+func withExif(jpg, segment []byte) []byte {
+	res := append([]byte{}, jpg[:2]...)
+	res = append(res, segment...)
+	return append(res, jpg[2:]...)
+}
+
+// halves returns a width x height JPEG whose left half is red and right
+// half blue, so how it was turned can be told after a lossy encoding.
+func halves(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode() = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestJpegOrientation(t *testing.T) {
+	jpg := halves(t, 32, 16)
+	truncated := exifSegment(binary.LittleEndian, 6)
+	binary.BigEndian.PutUint16(truncated[2:], 0xfff0)
+
+	tests := []struct {
+		name string
+		file []byte
+		want int
+	}{
+		{"no exif", jpg, 1},
+		{"little endian", withExif(jpg, exifSegment(binary.LittleEndian, 6)), 6},
+		{"big endian", withExif(jpg, exifSegment(binary.BigEndian, 8)), 8},
+		{"out of range", withExif(jpg, exifSegment(binary.LittleEndian, 9)), 1},
+		{"segment past the end", append([]byte{0xff, 0xd8}, truncated...), 1},
+		{"not a jpeg", []byte("GIF89a"), 1},
+		{"empty", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.file); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	a := color.NRGBA{R: 1, A: 255}
+	b := color.NRGBA{R: 2, A: 255}
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, a)
+	src.SetNRGBA(1, 0, b)
+
+	tests := []struct {
+		orientation int
+		// the pixels of the result, row by row
+		want [][]color.NRGBA
+	}{
+		{1, [][]color.NRGBA{{a, b}}},
+		{2, [][]color.NRGBA{{b, a}}},
+		{3, [][]color.NRGBA{{b, a}}},
+		{6, [][]color.NRGBA{{a}, {b}}},
+		{8, [][]color.NRGBA{{b}, {a}}},
+	}
+
+	for _, tt := range tests {
+		out := toNRGBA(orient(src, tt.orientation))
+		if out.Rect.Dx() != len(tt.want[0]) || out.Rect.Dy() != len(tt.want) {
+			t.Errorf("orient(%d) is %dx%d, want %dx%d", tt.orientation, out.Rect.Dx(), out.Rect.Dy(), len(tt.want[0]), len(tt.want))
+			continue
+		}
+		for y, row := range tt.want {
+			for x, want := range row {
+				if got := out.NRGBAAt(x, y); got != want {
+					t.Errorf("orient(%d) at %d,%d = %v, want %v", tt.orientation, x, y, got, want)
+				}
+			}
+		}
+	}
+}
@@ -1,5 +1,212 @@
 package utils
 
-func ResizeImage(file []byte, width, height int) ([]byte, error) {
-	return file, nil
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// ImageTypes are the content types of the formats pictures may be uploaded
+// in, keyed by the names image.Decode gives them.
+var ImageTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// imageSignatures are the bytes files of each format in ImageTypes start
+// with.
+var imageSignatures = []struct {
+	format    string
+	signature string
+}{
+	{"jpeg", "\xff\xd8\xff"},
+	{"png", "\x89PNG\r\n\x1a\n"},
+	{"gif", "GIF87a"},
+	{"gif", "GIF89a"},
+}
+
+// SniffImage returns the format of the picture in file by its first bytes,
+// or an empty string when it is none of ImageTypes. Unlike
+// http.DetectContentType, text that looks like markup is never a picture.
+func SniffImage(file []byte) string {
+	for _, s := range imageSignatures {
+		if bytes.HasPrefix(file, []byte(s.signature)) {
+			return s.format
+		}
+	}
+	return ""
+}
+
+// ImageContentType returns the content type of the picture in file, or an
+// empty string when it is none of ImageTypes.
+func ImageContentType(file []byte) string {
+	return ImageTypes[SniffImage(file)]
+}
+
+// ImageConfig returns the format of the picture in file and its size as
+// viewers show it, turned by the EXIF orientation of JPEGs. Only the
+// headers are read, so the size can be checked before decoding the pixels.
+func ImageConfig(file []byte) (format string, width, height int, err error) {
+	format = SniffImage(file)
+	if format == "" {
+		return "", 0, 0, fmt.Errorf("unknown image format")
+	}
+
+	config, decoded, err := image.DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("error to decode image config. Details: '%s'", err)
+	}
+	if decoded != format {
+		return "", 0, 0, fmt.Errorf("%s image decoded as %s", format, decoded)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return "", 0, 0, fmt.Errorf("image has no pixels")
+	}
+
+	width, height = config.Width, config.Height
+	if format == "jpeg" && swapsSides(jpegOrientation(file)) {
+		width, height = height, width
+	}
+	return format, width, height, nil
+}
+
+// CleanImage decodes the whole picture in file and encodes it again in the
+// same format, which verifies every pixel and leaves behind the metadata,
+// e.g. the EXIF camera details and GPS position of photos. JPEGs are turned
+// as their orientation tells first, since the tag goes away with the rest.
+// Only the first frame of animated GIFs is kept.
+func CleanImage(file []byte) ([]byte, error) {
+	format := SniffImage(file)
+	if format == "" {
+		return nil, fmt.Errorf("unknown image format")
+	}
+
+	src, decoded, err := image.Decode(bytes.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("error to decode image. Details: '%s'", err)
+	}
+	if decoded != format {
+		return nil, fmt.Errorf("%s image decoded as %s", format, decoded)
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, orient(src, jpegOrientation(file)), &jpeg.Options{Quality: 90})
+	case "png":
+		err = png.Encode(&buf, src)
+	case "gif":
+		err = gif.Encode(&buf, src, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error to encode image. Details: '%s'", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ResizeImage cuts crop out of the picture in file, or takes it whole when
+// crop is empty, and scales it to width x height as a PNG. Whatever does not
+// fit the proportions of the result is cut evenly from both sides, so faces
+// stay centered and nothing is stretched.
+func ResizeImage(file []byte, crop image.Rectangle, width, height int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("error to decode image. Details: '%s'", err)
+	}
+
+	bounds := src.Bounds()
+	if !crop.Empty() {
+		// the box comes from the browser, which may round it past the edges
+		bounds = crop.Add(bounds.Min).Intersect(bounds)
+		if bounds.Empty() {
+			return nil, fmt.Errorf("crop box %v is outside the image", crop)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scale(src, cover(bounds, width, height), width, height)); err != nil {
+		return nil, fmt.Errorf("error to encode image. Details: '%s'", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// cover returns the largest centered part of r with the proportions of
+// width x height.
+func cover(r image.Rectangle, width, height int) image.Rectangle {
+	w, h := r.Dx(), r.Dy()
+	if w*height > h*width {
+		w = h * width / height
+	} else {
+		h = w * height / width
+	}
+	if w == 0 || h == 0 {
+		return r
+	}
+
+	min := r.Min.Add(image.Pt((r.Dx()-w)/2, (r.Dy()-h)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
+
+// scale resizes the part r of src to width x height, averaging the source
+// pixels under each destination pixel. That keeps detail when shrinking
+// large photos, where sampling single pixels would alias.
+func scale(src image.Image, r image.Rectangle, width, height int) *image.NRGBA {
+	rgba := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, r.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, height, r.Dy())
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, width, r.Dx())
+
+			var sr, sg, sb, sa, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := rgba.NRGBAAt(sx, sy)
+					// weigh the colors by alpha, so transparent pixels do
+					// not darken the edges
+					sr += uint64(c.R) * uint64(c.A)
+					sg += uint64(c.G) * uint64(c.A)
+					sb += uint64(c.B) * uint64(c.A)
+					sa += uint64(c.A)
+					n++
+				}
+			}
+
+			var c color.NRGBA
+			if sa > 0 {
+				c = color.NRGBA{R: uint8(sr / sa), G: uint8(sg / sa), B: uint8(sb / sa), A: uint8(sa / n)}
+			}
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+
+	return dst
+}
+
+// span returns the source pixels [from, to) under destination pixel i of
+// n, for a source of size pixels. It is never empty, so enlarging a small
+// picture repeats its pixels.
+func span(i, n, size int) (from, to int) {
+	from = i * size / n
+	to = (i + 1) * size / n
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
+
+// toNRGBA returns src as NRGBA pixels with its top left corner at 0,0.
+func toNRGBA(src image.Image) *image.NRGBA {
+	bounds := src.Bounds()
+	rgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba
 }
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// pngHeader returns the signature and header chunk of a width x height
+// PNG, without any pixels.
+func pngHeader(width, height int) []byte {
+	chunk := make([]byte, 4+13)
+	copy(chunk, "IHDR")
+	binary.BigEndian.PutUint32(chunk[4:], uint32(width))
+	binary.BigEndian.PutUint32(chunk[8:], uint32(height))
+	chunk[12] = 8 // bits per sample
+	chunk[13] = 2 // truecolor
+
+	res := []byte("\x89PNG\r\n\x1a\n")
+	res = binary.BigEndian.AppendUint32(res, 13)
+	res = append(res, chunk...)
+	return binary.BigEndian.AppendUint32(res, crc32.ChecksumIEEE(chunk))
+}
+
+func encoded(t *testing.T, format string) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, 4, 3), color.Palette{color.Black, color.White})
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s = %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+var markup = map[string][]byte{
+	"svg":      []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><rect width="4" height="3"/></svg>`),
+	"xml svg":  []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`),
+	"html":     []byte(`<!DOCTYPE html><html><body><script>alert(1)</script></body></html>`),
+	"bom html": []byte("\xef\xbb\xbf<html><script>alert(1)</script></html>"),
+}
+
+func TestSniffImage(t *testing.T) {
+	for _, format := range []string{"jpeg", "png", "gif"} {
+		if got := SniffImage(encoded(t, format)); got != format {
+			t.Errorf("SniffImage(%s) = %q, want %q", format, got, format)
+		}
+	}
+
+	for name, file := range markup {
+		if got := SniffImage(file); got != "" {
+			t.Errorf("SniffImage(%s) = %q, want none", name, got)
+		}
+	}
+	if got := SniffImage(nil); got != "" {
+		t.Errorf("SniffImage(nil) = %q, want none", got)
+	}
+}
+
+func TestImageConfig(t *testing.T) {
+	jpg := halves(t, 32, 16)
+
+	tests := []struct {
+		name          string
+		file          []byte
+		format        string
+		width, height int
+	}{
+		{"png", encoded(t, "png"), "png", 4, 3},
+		{"gif", encoded(t, "gif"), "gif", 4, 3},
+		{"jpeg", jpg, "jpeg", 32, 16},
+		{"jpeg turned a quarter", withExif(jpg, exifSegment(binary.LittleEndian, 6)), "jpeg", 16, 32},
+		{"jpeg upside down", withExif(jpg, exifSegment(binary.LittleEndian, 3)), "jpeg", 32, 16},
+		// only the header is there, which is all the size takes
+		{"header only", pngHeader(100000, 80000), "png", 100000, 80000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, width, height, err := ImageConfig(tt.file)
+			if err != nil {
+				t.Fatalf("ImageConfig() = %v", err)
+			}
+			if format != tt.format || width != tt.width || height != tt.height {
+				t.Errorf("ImageConfig() = %s %dx%d, want %s %dx%d", format, width, height, tt.format, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestImageConfigRejects(t *testing.T) {
+	files := map[string][]byte{
+		"no pixels": pngHeader(0, 0),
+		"empty":     nil,
+	}
+	for name, file := range markup {
+		files[name] = file
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			if format, _, _, err := ImageConfig(file); err == nil {
+				t.Errorf("ImageConfig() = %s, want an error", format)
+			}
+		})
+	}
+}
+
+func TestCleanImage(t *testing.T) {
+	for _, format := range []string{"jpeg", "png", "gif"} {
+		clean, err := CleanImage(encoded(t, format))
+		if err != nil {
+			t.Fatalf("CleanImage(%s) = %v", format, err)
+		}
+		if got := SniffImage(clean); got != format {
+			t.Errorf("CleanImage(%s) is %q, want the same format", format, got)
+		}
+	}
+}
+
+func TestCleanImageTurnsJpegs(t *testing.T) {
+	file := withExif(halves(t, 32, 16), exifSegment(binary.LittleEndian, 6))
+
+	clean, err := CleanImage(file)
+	if err != nil {
+		t.Fatalf("CleanImage() = %v", err)
+	}
+
+	if bytes.Contains(clean, []byte("Exif")) || jpegOrientation(clean) != 1 {
+		t.Errorf("CleanImage() kept the EXIF data")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(clean))
+	if err != nil {
+		t.Fatalf("jpeg.Decode() = %v", err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(16, 32) {
+		t.Fatalf("CleanImage() is %v, want 16x32", size)
+	}
+	// turned a quarter clockwise, the red left half is now on top
+	for _, p := range []struct {
+		x, y int
+		red  bool
+	}{{8, 4, true}, {8, 28, false}} {
+		r, _, b, _ := img.At(p.x, p.y).RGBA()
+		if (r > b) != p.red {
+			t.Errorf("pixel %d,%d is red %v, want %v", p.x, p.y, r > b, p.red)
+		}
+	}
+}
+
+func TestCleanImageRejects(t *testing.T) {
+	files := map[string][]byte{
+		"header only": pngHeader(100000, 80000),
+		"truncated":   encoded(t, "png")[:40],
+		// the headers of a GIF signature followed by markup pass for a
+		// picture, the pixels do not
+		"gif signature on markup": append([]byte("GIF89a"), markup["html"]...),
+	}
+	for name, file := range markup {
+		files[name] = file
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			if _, err := CleanImage(file); err == nil {
+				t.Errorf("CleanImage() = nil, want an error")
+			}
+		})
+	}
+}
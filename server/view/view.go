@@ -0,0 +1,79 @@
+package view
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Templates holds one template set per page. Every set is a clone of the
+// files under layout/, which define "main", plus a single file under pages/
+// that fills in the "head" and "body" blocks.
+type Templates struct {
+	fsys   fs.FS
+	funcs  template.FuncMap
+	reload bool
+	pages  map[string]*template.Template
+}
+
+// New parses every page of fsys once. With reload set the files are parsed
+// again on each Render instead, so edits show up without a restart.
+func New(fsys fs.FS, funcs template.FuncMap, reload bool) (*Templates, error) {
+	t := &Templates{fsys: fsys, funcs: funcs, reload: reload}
+
+	pages, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.pages = pages
+
+	return t, nil
+}
+
+func (t *Templates) parse() (map[string]*template.Template, error) {
+	layout, err := template.New("layout").Funcs(t.funcs).ParseFS(t.fsys, "layout/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("error to parse layout templates: %v", err)
+	}
+
+	files, err := fs.Glob(t.fsys, "pages/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		page, err := layout.Clone()
+		if err == nil {
+			page, err = page.ParseFS(t.fsys, file)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error to parse template '%s': %v", file, err)
+		}
+
+		pages[strings.TrimSuffix(path.Base(file), ".html")] = page
+	}
+
+	return pages, nil
+}
+
+// Render executes the page called name, e.g. "home" for pages/home.html.
+func (t *Templates) Render(w io.Writer, name string, data interface{}) error {
+	pages := t.pages
+	if t.reload {
+		var err error
+		if pages, err = t.parse(); err != nil {
+			return err
+		}
+	}
+
+	page, exist := pages[name]
+	if !exist {
+		return fmt.Errorf("template '%s' not found", name)
+	}
+
+	return page.ExecuteTemplate(w, "main", data)
+}
@@ -0,0 +1,8 @@
+// Package static embeds the templates and the vendored css, fonts and scripts
+// so the binary can be run from any working directory, without a CDN.
+package static
+
+import "embed"
+
+//go:embed templates assets
+var Files embed.FS